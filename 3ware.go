@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tw_cli status values to normalized state
+var (
+	threeWareLDStates = StateMap{
+		"OK":             StateOK,
+		"VERIFYING":      StateOK,
+		"VERIFY-PAUSED":  StateOK,
+		"INITIALIZING":   StateOK,
+		"INIT-PAUSED":    StateOK,
+		"REBUILDING":     StateWarning,
+		"REBUILD-PAUSED": StateWarning,
+		"MIGRATING":      StateWarning,
+		"MIGRATE-PAUSED": StateWarning,
+		"RECOVERY":       StateWarning,
+		"DEGRADED":       StateCritical,
+		"INOPERABLE":     StateCritical,
+		"OFFLINE":        StateCritical,
+	}
+	threeWarePDStates = StateMap{
+		"OK":            StateOK,
+		"VERIFYING":     StateOK,
+		"INITIALIZING":  StateOK,
+		"REBUILDING":    StateWarning,
+		"SMART-FAILURE": StateWarning,
+		"ECC-ERROR":     StateWarning,
+		"DEGRADED":      StateCritical,
+		"DEVICE-ERROR":  StateCritical,
+		"OFFLINE":       StateCritical,
+		"UNSUPPORTED":   StateCritical,
+	}
+)
+
+type ThreeWareVendor struct {
+	execPath string
+	runner   Runner
+}
+
+// GetControllersIDs - get number of controllers in the system
+func (v ThreeWareVendor) GetControllersIDs() ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "show")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "(?m)^c(\\d+)\\s"), nil
+}
+
+// GetLogicalDrivesIDs - get number of units for controller with ID 'controllerID'
+func (v ThreeWareVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, fmt.Sprintf("/c%s", controllerID), "show", "all")
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, v := range FindRegexpSubmatches(inputData, "(?m)^u(\\d+)\\s", -1) {
+		data = append(data, v[1])
+	}
+
+	return data, nil
+}
+
+// GetPhysicalDrivesIDs - get number of ports with drives for controller with ID 'controllerID'
+func (v ThreeWareVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, fmt.Sprintf("/c%s", controllerID), "show", "all")
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, v := range FindRegexpSubmatches(inputData, "(?m)^p(\\d+)\\s+(\\S+)", -1) {
+		if v[2] != "NOT-PRESENT" {
+			data = append(data, v[1])
+		}
+	}
+
+	return data, nil
+}
+
+// GetControllerStatus - get controller status, state is worst of units and BBU
+func (v ThreeWareVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	args := []string{fmt.Sprintf("/c%s", controllerID), "show", "all"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return Controller{}, err
+	}
+
+	model := GetRegexpSubmatch(inputData, "(?m)^/c\\d+ Model = (.*)$")
+	if len(model) == 0 {
+		return Controller{}, NewParseError("controller model", v.execPath, args...)
+	}
+
+	healthStatuses := []string{}
+	state := StateOK
+
+	for _, u := range FindRegexpSubmatches(inputData, "(?m)^u(\\d+)\\s+\\S+\\s+(\\S+)", -1) {
+		s := threeWareLDStates.Get(u[2])
+		if s != StateOK {
+			healthStatuses = append(healthStatuses, fmt.Sprintf("unit %s is %s", u[1], u[2]))
+		}
+
+		state = state.Worse(s)
+	}
+
+	// Name OnlineState BBUReady Status Volt Temp Hours LastCapTest
+	batteryStatus := GetRegexpSubmatch(inputData, "(?m)^bbu\\s+\\S+\\s+\\S+\\s+(\\S+)")
+	if len(batteryStatus) > 0 && batteryStatus != "OK" {
+		healthStatuses = append(healthStatuses, fmt.Sprintf("BBU is %s", batteryStatus))
+		state = state.Worse(StateWarning)
+	}
+
+	status := "OK"
+	if len(healthStatuses) > 0 {
+		status = strings.Join(healthStatuses, "; ")
+	}
+
+	data := Controller{
+		Status:        status,
+		Health:        NewHealth(state),
+		Model:         TrimSpacesLeftAndRight(model),
+		BatteryStatus: batteryStatus,
+	}
+
+	return data, nil
+}
+
+// GetLDStatus - get unit status with rebuild, verify or initialization progress
+func (v ThreeWareVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	args := []string{fmt.Sprintf("/c%s/u%s", controllerID, deviceID), "show", "all"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return LogicalDrive{}, err
+	}
+
+	status := TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "(?m)^/c\\d+/u\\d+ status = (.*)$"))
+	if len(status) == 0 {
+		return LogicalDrive{}, NewParseError("unit status", v.execPath, args...)
+	}
+
+	// Unit UnitType Status %RCmpl %V/I/M Port Stripe Size(GB)
+	unit := FindRegexpSubmatches(inputData, fmt.Sprintf("(?m)^u%s\\s+(\\S+)\\s+\\S+\\s+\\S+\\s+\\S+\\s+\\S+\\s+\\S+\\s+(\\S+)", deviceID), 1)
+
+	var failed int
+	for _, d := range FindRegexpSubmatches(inputData, fmt.Sprintf("(?m)^u%s-\\d+\\s+DISK\\s+(\\S+)", deviceID), -1) {
+		if threeWarePDStates.Get(d[1]) == StateCritical {
+			failed++
+		}
+	}
+
+	data := LogicalDrive{
+		Status:       status,
+		Health:       NewHealth(threeWareLDStates.Get(status)),
+		Name:         TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "(?m)^/c\\d+/u\\d+ name = (.*)$")),
+		Progress:     GetRegexpSubmatch(inputData, "is (?:rebuilding|verifying|initializing|migrating) with percent completion = (\\d+)%"),
+		FailedDrives: strconv.Itoa(failed),
+	}
+
+	if len(unit) > 0 {
+		data.RaidMode = unit[0][1]
+		data.Size = unit[0][2] + " GB"
+	}
+
+	return data, nil
+}
+
+// GetPDStatus - get drive status on port 'deviceID'
+func (v ThreeWareVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	args := []string{fmt.Sprintf("/c%s/p%s", controllerID, deviceID), "show", "all"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return PhysicalDrive{}, err
+	}
+
+	status := TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "(?m)^/c\\d+/p\\d+ Status = (.*)$"))
+	if len(status) == 0 {
+		return PhysicalDrive{}, NewParseError("drive status", v.execPath, args...)
+	}
+
+	data := PhysicalDrive{
+		Status:             status,
+		Health:             NewHealth(threeWarePDStates.Get(status)),
+		Model:              TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "(?m)^/c\\d+/p\\d+ Model = (.*)$")),
+		FirmwareVersion:    TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "(?m)^/c\\d+/p\\d+ Firmware Version = (.*)$")),
+		Size:               GetRegexpSubmatch(inputData, "(?m)^/c\\d+/p\\d+ Capacity = (.*?) \\("),
+		CurrentSpeed:       TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "(?m)^/c\\d+/p\\d+ Link Speed = (.*)$")),
+		CurrentTemperature: GetRegexpSubmatch(inputData, "(?m)^/c\\d+/p\\d+ Temperature = (\\d+)"),
+		ReallocatedSectors: GetRegexpSubmatch(inputData, "(?m)^/c\\d+/p\\d+ Reallocated Sectors = (\\d+)"),
+		PowerOnHours:       GetRegexpSubmatch(inputData, "(?m)^/c\\d+/p\\d+ Power On Hours = (\\d+)"),
+	}
+
+	return data, nil
+}
+
+// threeWareDevices - character device of controller for driver, numbered by controller
+var threeWareDevices = map[string]string{
+	"3w-xxxx": "/dev/twe%d",
+	"3w-9xxx": "/dev/twa%d",
+	"3w-sas":  "/dev/twl%d",
+}
+
+// SmartDevices - drives behind 3ware controller are addressed by their port on controller's character device
+func (v ThreeWareVendor) SmartDevices(controllerID string, host ControllerHost) (map[string]SmartDevice, error) {
+	ids, err := v.GetPhysicalDrivesIDs(controllerID)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := host.Host("3w-xxxx", "3w-9xxx", "3w-sas")
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]SmartDevice{}
+
+	for _, id := range ids {
+		data[id] = SmartDevice{Type: "3ware," + id, Path: fmt.Sprintf(threeWareDevices[h.Driver], host.Index)}
+	}
+
+	return data, nil
+}
+
+// NewThreeWareVendor - 3ware/AMCC 9000 series controllers managed by tw_cli
+func NewThreeWareVendor(execPath string, runner Runner) Vendor {
+	v := ThreeWareVendor{execPath: execPath, runner: runner}
+	return v
+}
@@ -2,106 +2,158 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"strings"
 )
 
+// arcconf status values to normalized state
+var (
+	adaptecControllerStates = StateMap{
+		"Optimal": StateOK,
+		"Okay":    StateOK,
+		"Failed":  StateCritical,
+	}
+	adaptecLDStates = StateMap{
+		"Optimal":    StateOK,
+		"Okay":       StateOK,
+		"Building":   StateWarning,
+		"Rebuilding": StateWarning,
+		"Impacted":   StateWarning,
+		"Suboptimal": StateWarning,
+		"Degraded":   StateCritical,
+		"Failed":     StateCritical,
+		"Offline":    StateCritical,
+	}
+	adaptecPDStates = StateMap{
+		"Online":          StateOK,
+		"Hot Spare":       StateOK,
+		"Ready":           StateOK,
+		"Raw (Pass Thru)": StateOK,
+		"Rebuilding":      StateWarning,
+		"Failed":          StateCritical,
+		"Missing":         StateCritical,
+		"Offline":         StateCritical,
+	}
+)
+
 type AdaptecVendor struct {
 	execPath string
+	runner   Runner
 }
 
 // GetControllersIDs - get number of controllers in the system
-func (v AdaptecVendor) GetControllersIDs() []string {
-	inputData := GetCommandOutput(v.execPath, "list")
-	return GetRegexpAllSubmatch(inputData, "Controller ([^a-zA-Z].*?):")
+func (v AdaptecVendor) GetControllersIDs() ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "Controller ([^a-zA-Z].*?):"), nil
 }
 
 // GetLogicalDrivesIDs - get number of logical drives for controller with ID 'controllerID'
-func (v AdaptecVendor) GetLogicalDrivesIDs(controllerID string) []string {
-	inputData := GetCommandOutput(v.execPath, "getconfig", controllerID, "ld")
-	return GetRegexpAllSubmatch(inputData, "Logical Device number (.*)[\\s]")
+func (v AdaptecVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "getconfig", controllerID, "ld")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "Logical Device number (.*)[\\s]"), nil
 }
 
 // GetPhysicalDrivesIDs - get number of physical drives for controller with ID 'controllerID'
-func (v AdaptecVendor) GetPhysicalDrivesIDs(controllerID string) []string {
-	inputData := GetCommandOutput(v.execPath, "getconfig", controllerID, "pd")
-	return GetRegexpAllSubmatch(inputData, "Device is a Hard drive[\\s\\S]*?Reported Channel,Device\\(T:L\\)[\\s]*[:][\\s](.*?)\\(.*\\)[\\s]")
+func (v AdaptecVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "getconfig", controllerID, "pd")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "Device is a Hard drive[\\s\\S]*?Reported Channel,Device\\(T:L\\)[\\s]*[:][\\s](.*?)\\(.*\\)[\\s]"), nil
 }
 
 // GetControllerStatus - get controller status
-func (v AdaptecVendor) GetControllerStatus(controllerID string, indent int) []byte {
-	type ReturnData struct {
-		Status      string `json:"status"`
-		Model       string `json:"model"`
-		Temperature string `json:"temperature"`
+func (v AdaptecVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	args := []string{"getconfig", controllerID, "ad"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return Controller{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "getconfig", controllerID, "ad")
 	status := GetRegexpSubmatch(inputData, "Controller Status *: (.*)")
+	if len(status) == 0 {
+		return Controller{}, NewParseError("controller status", v.execPath, args...)
+	}
 	model := GetRegexpSubmatch(inputData, "Controller Model *: (.*)")
 	temperature := GetRegexpSubmatch(inputData, "Temperature *: (.*) C")
+	health := NewHealth(adaptecControllerStates.Get(status))
 
 	if status == "Optimal" {
 		status = "OK"
 	}
 
-	data := ReturnData{
+	data := Controller{
 		Status:      TrimSpacesLeftAndRight(status),
+		Health:      health,
 		Model:       TrimSpacesLeftAndRight(model),
 		Temperature: TrimSpacesLeftAndRight(temperature),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
 // GetLDStatus - get logical drive status
-func (v AdaptecVendor) GetLDStatus(controllerID string, deviceID string, indent int) []byte {
-	type ReturnData struct {
-		Status string `json:"status"`
-		Size   string `json:"size"`
+func (v AdaptecVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	args := []string{"getconfig", controllerID, "ld", deviceID}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return LogicalDrive{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "getconfig", controllerID, "ld", deviceID)
 	status := GetRegexpSubmatch(inputData, "Status of Logical Device *: (.*)")
+	if len(status) == 0 {
+		return LogicalDrive{}, NewParseError("logical drive status", v.execPath, args...)
+	}
 	size := GetRegexpSubmatch(inputData, "Size *: (.*)")
+	health := NewHealth(adaptecLDStates.Get(status))
 
 	if status == "Optimal" {
 		status = "OK"
 	}
 
-	data := ReturnData{
+	data := LogicalDrive{
 		Status: TrimSpacesLeftAndRight(status),
+		Health: health,
 		Size:   TrimSpacesLeftAndRight(size),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
 // GetPDStatus - get physical drive status
-func (v AdaptecVendor) GetPDStatus(controllerID string, deviceID string, indent int) []byte {
-	type ReturnData struct {
-		Status      string `json:"status"`
-		Model       string `json:"model"`
-		Smart       string `json:"smart"`
-		SmartWarn   string `json:"smartwarnings"`
-		TotalSize   string `json:"totalsize"`
-		Temperature string `json:"temperature"`
-	}
-
+func (v AdaptecVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
 	deviceData := strings.Split(deviceID, ",")
 	if len(deviceData) < 2 {
-		fmt.Printf("Error - wrong device id '%s'.\n", deviceID)
-		os.Exit(1)
+		return PhysicalDrive{}, NewUnknownDeviceError("wrong device id '%s'", deviceID)
+	}
 
+	args := []string{"getconfig", controllerID, "pd", deviceData[0], deviceData[1]}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return PhysicalDrive{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "getconfig", controllerID, "pd", deviceData[0], deviceData[1])
 	status := GetRegexpSubmatch(inputData, "[\\s]{2}State *: (.*)")
+	if len(status) == 0 {
+		return PhysicalDrive{}, NewParseError("physical drive status", v.execPath, args...)
+	}
 	model := GetRegexpSubmatch(inputData, "Model *: (.*)")
 	smart := GetRegexpSubmatch(inputData, "S.M.A.R.T. *: (.*)")
 	smartWarn := GetRegexpSubmatch(inputData, "S.M.A.R.T. warnings *: (.*)")
 	totalSize := GetRegexpSubmatch(inputData, "Total Size *: (.*)")
 	temperature := GetRegexpSubmatch(inputData, "Temperature *: (.*) C")
+	health := NewHealth(adaptecPDStates.Get(status))
 
 	if status == "Online" {
 		status = "OK"
@@ -111,8 +163,9 @@ func (v AdaptecVendor) GetPDStatus(controllerID string, deviceID string, indent
 		smart = "OK"
 	}
 
-	data := ReturnData{
+	data := PhysicalDrive{
 		Status:      TrimSpacesLeftAndRight(status),
+		Health:      health,
 		Model:       TrimSpacesLeftAndRight(model),
 		Smart:       TrimSpacesLeftAndRight(smart),
 		SmartWarn:   TrimSpacesLeftAndRight(smartWarn),
@@ -120,10 +173,41 @@ func (v AdaptecVendor) GetPDStatus(controllerID string, deviceID string, indent
 		Temperature: TrimSpacesLeftAndRight(temperature),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
+}
+
+// SmartDevices - drives behind Adaptec controller are addressed by controller's SCSI host and device id
+func (v AdaptecVendor) SmartDevices(controllerID string, host ControllerHost) (map[string]SmartDevice, error) {
+	ids, err := v.GetPhysicalDrivesIDs(controllerID)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := host.Host("aacraid")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(h.Generic) == 0 {
+		return nil, fmt.Errorf("no SCSI generic device of host%d", h.Number)
+	}
+
+	data := map[string]SmartDevice{}
+
+	for _, id := range ids {
+		deviceData := strings.Split(id, ",")
+		if len(deviceData) < 2 {
+			continue
+		}
+
+		// smartctl addresses drive as 'aacraid,<host>,<lun>,<target id>', drives are LUN 0 of their device id
+		data[id] = SmartDevice{Type: fmt.Sprintf("aacraid,%d,0,%s", h.Number, deviceData[1]), Path: h.Generic}
+	}
+
+	return data, nil
 }
 
-func NewAdaptecVendor(execPath string) Vendor {
-	v := AdaptecVendor{execPath: execPath}
+func NewAdaptecVendor(execPath string, runner Runner) Vendor {
+	v := AdaptecVendor{execPath: execPath, runner: runner}
 	return v
 }
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// cli64 status values to normalized state
+var (
+	arecaRaidSetStates = StateMap{
+		"Normal":       StateOK,
+		"Initializing": StateOK,
+		"Rebuilding":   StateWarning,
+		"Migrating":    StateWarning,
+		"Degraded":     StateCritical,
+		"Incompleted":  StateCritical,
+		"Offline":      StateCritical,
+		"Failed":       StateCritical,
+	}
+	arecaVolumeStates = StateMap{
+		"Normal":       StateOK,
+		"Checking":     StateOK,
+		"Initializing": StateOK,
+		"Rebuilding":   StateWarning,
+		"Migrating":    StateWarning,
+		"Degraded":     StateCritical,
+		"Failed":       StateCritical,
+		"Offline":      StateCritical,
+	}
+	arecaDiskStates = StateMap{
+		"NORMAL":     StateOK,
+		"REBUILDING": StateWarning,
+		"FAILED":     StateCritical,
+	}
+)
+
+// arecaEmptySlot - model of slot without disk in 'disk info' table
+const arecaEmptySlot = "N.A."
+
+// arecaResult - result code and message printed after every command
+var arecaResult = regexp.MustCompile(`GuiErrMsg<0x([0-9A-Fa-f]+)>: (.*)`)
+
+type ArecaVendor struct {
+	execPath string
+	runner   Runner
+}
+
+// run - run command for controller 'controllerID', controller is selected with 'ctrl=N' in every command
+func (v ArecaVendor) run(controllerID string, args ...string) ([]byte, error) {
+	args = append([]string{fmt.Sprintf("ctrl=%s", controllerID)}, args...)
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if m := arecaResult.FindSubmatch(inputData); m != nil && string(m[1]) != "00" {
+		command := commandLine(v.execPath, args)
+		return nil, &CommandError{Code: ErrorCodeGeneric, Command: command, Err: fmt.Errorf("command '%s' failed: %s", command, strings.TrimSpace(string(m[2])))}
+	}
+
+	return inputData, nil
+}
+
+// GetControllersIDs - get number of controllers in the system
+func (v ArecaVendor) GetControllersIDs() ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "main")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "(?m)^\\[[ *]\\]\\s+(\\d+)\\s"), nil
+}
+
+// GetLogicalDrivesIDs - get number of volume sets for controller with ID 'controllerID'
+func (v ArecaVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.run(controllerID, "vsf", "info")
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, v := range FindRegexpSubmatches(inputData, "(?m)^\\s*(\\d+) ", -1) {
+		data = append(data, v[1])
+	}
+
+	return data, nil
+}
+
+// GetPhysicalDrivesIDs - get number of disks for controller with ID 'controllerID', empty slots are skipped
+func (v ArecaVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.run(controllerID, "disk", "info")
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	// # Enc# Slot# ModelName Capacity Usage
+	for _, v := range FindRegexpSubmatches(inputData, "(?m)^\\s*(\\d+)\\s+\\d+\\s+Slot#\\d+\\s+(\\S+)", -1) {
+		if v[2] != arecaEmptySlot {
+			data = append(data, v[1])
+		}
+	}
+
+	return data, nil
+}
+
+// GetControllerStatus - get controller status, state is worst of raid sets, battery and fan
+func (v ArecaVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	sysInfo, err := v.run(controllerID, "sys", "info")
+	if err != nil {
+		return Controller{}, err
+	}
+
+	model := TrimSpacesLeftAndRight(GetRegexpSubmatch(sysInfo, "(?m)^Controller Name\\s*: (.*)$"))
+	if len(model) == 0 {
+		return Controller{}, NewParseError("controller name", v.execPath, fmt.Sprintf("ctrl=%s", controllerID), "sys", "info")
+	}
+
+	rsfInfo, err := v.run(controllerID, "rsf", "info")
+	if err != nil {
+		return Controller{}, err
+	}
+
+	healthStatuses := []string{}
+	state := StateOK
+
+	// # Name Disks TotalCap FreeCap MinDiskCap State
+	for _, r := range FindRegexpSubmatches(rsfInfo, "(?m)^\\s*\\d+\\s+(.*?)\\s+\\d+\\s+\\S+GB\\s+\\S+GB\\s+\\S+GB\\s+(\\S+)\\s*$", -1) {
+		s := arecaRaidSetStates.Get(r[2])
+		if s != StateOK {
+			healthStatuses = append(healthStatuses, fmt.Sprintf("%s is %s", r[1], r[2]))
+		}
+
+		state = state.Worse(s)
+	}
+
+	hwInfo, err := v.run(controllerID, "hw", "info")
+	if err != nil {
+		return Controller{}, err
+	}
+
+	temperature := GetRegexpSubmatch(hwInfo, "(?m)^Controller Temp\\.\\s*: (\\d+)")
+	if len(temperature) == 0 {
+		temperature = GetRegexpSubmatch(hwInfo, "(?m)^CPU Temperature\\s*: (\\d+)")
+	}
+
+	fanSpeed := GetRegexpSubmatch(hwInfo, "(?m)^Fan#1 Speed \\(RPM\\)\\s*: (\\d+)")
+	if fanSpeed == "0" {
+		healthStatuses = append(healthStatuses, "fan is stopped")
+		state = state.Worse(StateWarning)
+	}
+
+	// charge level when battery is fine, 'Not Installed' or problem otherwise
+	batteryStatus := TrimSpacesLeftAndRight(GetRegexpSubmatch(hwInfo, "(?m)^Battery Status\\s*: (.*)$"))
+	if charge := GetRegexpSubmatch([]byte(batteryStatus), "^(\\d+%)$"); len(charge) != 0 {
+		batteryStatus = fmt.Sprintf("OK (%s)", charge)
+	} else if batteryStatus == "Not Installed" {
+		batteryStatus = ""
+	} else if len(batteryStatus) != 0 {
+		healthStatuses = append(healthStatuses, fmt.Sprintf("battery is %s", batteryStatus))
+		state = state.Worse(StateWarning)
+	}
+
+	status := "OK"
+	if len(healthStatuses) > 0 {
+		status = strings.Join(healthStatuses, "; ")
+	}
+
+	data := Controller{
+		Status:        status,
+		Health:        NewHealth(state),
+		Model:         model,
+		BatteryStatus: batteryStatus,
+		Temperature:   temperature,
+		FanSpeed:      fanSpeed,
+	}
+
+	return data, nil
+}
+
+// GetLDStatus - get volume set status with rebuild, check or initialization progress
+func (v ArecaVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	inputData, err := v.run(controllerID, "vsf", "info")
+	if err != nil {
+		return LogicalDrive{}, err
+	}
+
+	// # Name Raid Name Level Capacity Ch/Id/Lun State
+	volume := FindRegexpSubmatches(inputData, fmt.Sprintf("(?m)^\\s*%s (\\S+)\\s+.*?\\s+(Raid\\S+)\\s+(\\S+)\\s+\\d+/\\d+/\\d+\\s+(\\S+)\\s*$", regexp.QuoteMeta(deviceID)), 1)
+	if len(volume) == 0 {
+		return LogicalDrive{}, NewUnknownDeviceError("volume set '%s' not found on controller '%s'", deviceID, controllerID)
+	}
+
+	// 'Rebuilding(34.5%)'
+	status := volume[0][4]
+	var progress string
+	if i := strings.Index(status, "("); i > 0 {
+		progress = strings.TrimSuffix(strings.TrimSuffix(status[i+1:], ")"), "%")
+		status = status[:i]
+	}
+
+	health := NewHealth(arecaVolumeStates.Get(status))
+
+	if status == "Normal" {
+		status = "OK"
+	}
+
+	data := LogicalDrive{
+		Status:   status,
+		Health:   health,
+		Name:     volume[0][1],
+		Size:     volume[0][3],
+		RaidMode: volume[0][2],
+		Progress: progress,
+	}
+
+	return data, nil
+}
+
+// GetPDStatus - get disk status, 'deviceID' is disk number from 'disk info'
+func (v ArecaVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	args := []string{"disk", "info", fmt.Sprintf("drv=%s", deviceID)}
+
+	inputData, err := v.run(controllerID, args...)
+	if err != nil {
+		return PhysicalDrive{}, err
+	}
+
+	status := TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "(?m)^Device State\\s*: (.*)$"))
+	if len(status) == 0 {
+		return PhysicalDrive{}, NewParseError("device state", v.execPath, append([]string{fmt.Sprintf("ctrl=%s", controllerID)}, args...)...)
+	}
+
+	data := PhysicalDrive{
+		Status:             status,
+		Health:             NewHealth(arecaDiskStates.Get(status)),
+		Model:              TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "(?m)^Model Name\\s*: (.*)$")),
+		FirmwareVersion:    TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "(?m)^Firmware Rev\\.\\s*: (.*)$")),
+		Size:               TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "(?m)^Disk Capacity\\s*: (.*)$")),
+		CurrentTemperature: GetRegexpSubmatch(inputData, "(?m)^Device Temperature\\s*: (\\d+)"),
+		MediaErrors:        GetRegexpSubmatch(inputData, "(?m)^Media Error Count\\s*: (\\d+)"),
+	}
+
+	if data.Status == "NORMAL" {
+		data.Status = "OK"
+	}
+
+	return data, nil
+}
+
+// NewArecaVendor - Areca controllers managed by cli64
+func NewArecaVendor(execPath string, runner Runner) Vendor {
+	v := ArecaVendor{execPath: execPath, runner: runner}
+	return v
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultCacheDir = "/run/raidstat"
+
+// CacheConfig - shared tool output cache, disabled when 'TTL' is 0
+type CacheConfig struct {
+	Dir string `json:"dir"`
+	TTL int    `json:"ttl"`
+}
+
+// CacheRunner - shares tool output between processes through files in 'Dir' for 'TTL' seconds,
+// concurrent processes wait on file lock for the one running the command and read its result
+type CacheRunner struct {
+	Runner Runner
+	Vendor string
+	Dir    string
+	TTL    int
+}
+
+// argsRunner - runner adding own arguments to commands, they change output and are part of cache key
+type argsRunner interface {
+	CommandArgs(args []string) []string
+}
+
+// Run - return cached output for command or run it and store output, errors are not cached
+func (r CacheRunner) Run(execPath string, args ...string) ([]byte, error) {
+	keyArgs := args
+	if a, ok := r.Runner.(argsRunner); ok {
+		keyArgs = a.CommandArgs(args)
+	}
+
+	path := filepath.Join(r.Dir, cacheKey(r.Vendor, execPath, keyArgs))
+
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		// cache is unavailable, don't fail monitoring because of it
+		logger.Warnf("cache is unavailable: %s", err)
+		return r.Runner.Run(execPath, args...)
+	}
+	defer unlock()
+
+	if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) < time.Duration(r.TTL)*time.Second {
+		if data, err := os.ReadFile(path); err == nil {
+			logger.Debugf("command '%s' output read from cache '%s'", commandLine(execPath, args), path)
+			return data, nil
+		}
+	}
+
+	data, err := r.Runner.Run(execPath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// failed write only makes next process run the command again
+	writeFileAtomic(path, data, 0600)
+
+	return data, nil
+}
+
+// cacheKey - file name for vendor, binary and arguments
+func cacheKey(vendor string, execPath string, args []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(append([]string{vendor, execPath}, args...), "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// lockFile - take exclusive lock on file at 'path', creating it and its directory if needed
+func lockFile(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// writeFileAtomic - write data to temporary file and rename it to 'path'
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
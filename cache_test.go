@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingRunner - returns arguments as output and counts calls
+type countingRunner struct {
+	calls *int32
+	delay time.Duration
+	err   error
+}
+
+func (r countingRunner) Run(execPath string, args ...string) ([]byte, error) {
+	atomic.AddInt32(r.calls, 1)
+	time.Sleep(r.delay)
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return []byte(strings.Join(args, " ")), nil
+}
+
+func TestCacheRunner(t *testing.T) {
+	var calls int32
+	dir := filepath.Join(t.TempDir(), "raidstat")
+	r := CacheRunner{Runner: countingRunner{calls: &calls}, Vendor: "sas2ircu", Dir: dir, TTL: 60}
+
+	for i := 0; i < 3; i++ {
+		data, err := r.Run("sas2ircu", "0", "display")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if string(data) != "0 display" {
+			t.Errorf("got output %q, want '0 display'", data)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("command run %d times, want 1", calls)
+	}
+
+	if _, err := r.Run("sas2ircu", "1", "display"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("command with other arguments not run, calls %d, want 2", calls)
+	}
+
+	// expire cached output
+	path := filepath.Join(dir, cacheKey("sas2ircu", "sas2ircu", []string{"0", "display"}))
+	old := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Run("sas2ircu", "0", "display"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expired output not refreshed, calls %d, want 3", calls)
+	}
+}
+
+// argsCountingRunner - countingRunner appending configured arguments like ExecRunner
+type argsCountingRunner struct {
+	countingRunner
+	args []string
+}
+
+func (r argsCountingRunner) Run(execPath string, args ...string) ([]byte, error) {
+	return r.countingRunner.Run(execPath, r.CommandArgs(args)...)
+}
+
+func (r argsCountingRunner) CommandArgs(args []string) []string {
+	return append(args[:len(args):len(args)], r.args...)
+}
+
+func TestCacheRunnerConfiguredArgs(t *testing.T) {
+	var calls int32
+	dir := t.TempDir()
+
+	for _, args := range [][]string{nil, {"-NoLog"}, {"-NoLog"}} {
+		r := CacheRunner{Runner: argsCountingRunner{countingRunner: countingRunner{calls: &calls}, args: args}, Vendor: "megacli", Dir: dir, TTL: 60}
+
+		data, err := r.Run("megacli", "-PDList", "-a0")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if want := strings.Join(append([]string{"-PDList", "-a0"}, args...), " "); string(data) != want {
+			t.Errorf("configured arguments %q: got output %q, want %q", args, data, want)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("command run %d times, want 2, output of other configured arguments must not be reused", calls)
+	}
+}
+
+func TestCacheRunnerErrorsNotCached(t *testing.T) {
+	var calls int32
+	r := CacheRunner{Runner: countingRunner{calls: &calls, err: errors.New("timed out")}, Vendor: "hp", Dir: t.TempDir(), TTL: 60}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Run("ssacli", "ctrl", "all", "show"); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("failed command run %d times, want 2", calls)
+	}
+}
+
+func TestCacheRunnerConcurrent(t *testing.T) {
+	var (
+		calls int32
+		wg    sync.WaitGroup
+	)
+
+	dir := t.TempDir()
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			r := CacheRunner{Runner: countingRunner{calls: &calls, delay: 50 * time.Millisecond}, Vendor: "megacli", Dir: dir, TTL: 60}
+			if _, err := r.Run("megacli", "-PDList", "-a0", "-NoLog"); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("concurrent command run %d times, want 1", calls)
+	}
+}
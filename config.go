@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	configFile     = "config.json"
+	configEnv      = "RAIDSTAT_CONFIG"
+	defaultTimeout = 10
+)
+
+// configSearchPath - config locations checked in order when no path is given:
+// $RAIDSTAT_CONFIG, config.json next to the binary, /etc/raidstat/config.json
+func configSearchPath() []string {
+	var paths []string
+
+	if p := os.Getenv(configEnv); len(p) != 0 {
+		paths = append(paths, p)
+	}
+
+	if exe, err := os.Executable(); err == nil {
+		paths = append(paths, filepath.Join(filepath.Dir(exe), configFile))
+	}
+
+	return append(paths, filepath.Join("/etc/raidstat", configFile))
+}
+
+// Config - raidstat configuration
+type Config struct {
+	DefaultVendor string                  `json:"default_vendor"`
+	Timeout       int                     `json:"timeout"`
+	SysfsRoot     string                  `json:"sysfs_root"`
+	Cache         CacheConfig             `json:"cache"`
+	Log           LogConfig               `json:"log"`
+	Smart         SmartConfig             `json:"smart"`
+	Vendors       map[string]VendorConfig `json:"vendors"`
+}
+
+// VendorConfig - per-vendor settings
+type VendorConfig struct {
+	Binary    string                    `json:"binary"`
+	Root      string                    `json:"root"`
+	Args      []string                  `json:"args"`
+	Timeout   int                       `json:"timeout"`
+	StatusMap map[string]StatusOverride `json:"status_map"`
+}
+
+// StatusOverride - status replacing vendor status and its state name, status alone is its state
+// when it is state name ('OK'), otherwise state computed from vendor status is kept
+type StatusOverride struct {
+	Status string `json:"status"`
+	State  string `json:"state"`
+}
+
+// UnmarshalJSON - override is status string or object with status and state
+func (o *StatusOverride) UnmarshalJSON(data []byte) error {
+	var status string
+	if err := json.Unmarshal(data, &status); err == nil {
+		*o = StatusOverride{Status: status}
+		return nil
+	}
+
+	type override StatusOverride
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode((*override)(o))
+}
+
+// defaultConfig - config used when no config file is found
+func defaultConfig() Config {
+	return Config{
+		Timeout:   defaultTimeout,
+		SysfsRoot: defaultSysfsRoot,
+		Cache:     CacheConfig{Dir: defaultCacheDir},
+		Log:       LogConfig{Level: LogWarn.String(), Output: logOutputStderr},
+		Smart:     SmartConfig{Binary: defaultSmartBinary},
+	}
+}
+
+// LoadConfig - read config from 'path' or from first existing file in search path,
+// defaults are returned when path is empty and no config file is found
+func LoadConfig(path string) (Config, error) {
+	if len(path) == 0 {
+		for _, p := range configSearchPath() {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+	}
+
+	if len(path) == 0 {
+		return defaultConfig(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("error reading config: %w", err)
+	}
+
+	c, err := ParseConfig(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("error in config '%s': %w", path, err)
+	}
+
+	return c, nil
+}
+
+// ParseConfig - decode and validate config data
+func ParseConfig(data []byte) (Config, error) {
+	c := defaultConfig()
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return Config{}, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+// Validate - check config values
+func (c Config) Validate() error {
+	var errs []string
+
+	if len(c.DefaultVendor) != 0 {
+		if err := validateVendors(c.DefaultVendor); err != nil {
+			errs = append(errs, fmt.Sprintf("default_vendor: %s", err))
+		}
+	}
+
+	if c.Timeout <= 0 {
+		errs = append(errs, fmt.Sprintf("timeout: must be positive, got %d", c.Timeout))
+	}
+
+	if c.Cache.TTL < 0 {
+		errs = append(errs, fmt.Sprintf("cache.ttl: must not be negative, got %d", c.Cache.TTL))
+	}
+
+	if c.Cache.TTL > 0 && !filepath.IsAbs(c.Cache.Dir) {
+		errs = append(errs, fmt.Sprintf("cache.dir: must be absolute path, got '%s'", c.Cache.Dir))
+	}
+
+	if err := c.Log.Validate(); err != nil {
+		errs = append(errs, fmt.Sprintf("log: %s", err))
+	}
+
+	for name, vc := range c.Vendors {
+		if _, ok := vendorDefs[name]; !ok {
+			errs = append(errs, fmt.Sprintf("vendors: unknown vendor '%s'", name))
+			continue
+		}
+
+		if vendorDefs[name].files && len(vc.Binary) != 0 {
+			errs = append(errs, fmt.Sprintf("vendors.%s.binary: vendor reads files and has no tool, use 'root'", name))
+		}
+
+		if !vendorDefs[name].files && len(vc.Root) != 0 {
+			errs = append(errs, fmt.Sprintf("vendors.%s.root: vendor runs tool '%s', use 'binary'", name, vendorDefs[name].binary))
+		}
+
+		if len(vc.Root) != 0 && !filepath.IsAbs(vc.Root) {
+			errs = append(errs, fmt.Sprintf("vendors.%s.root: must be absolute path, got '%s'", name, vc.Root))
+		}
+
+		if vc.Timeout < 0 {
+			errs = append(errs, fmt.Sprintf("vendors.%s.timeout: must not be negative, got %d", name, vc.Timeout))
+		}
+
+		for status, o := range vc.StatusMap {
+			if _, ok := ParseState(o.State); len(o.State) != 0 && !ok {
+				errs = append(errs, fmt.Sprintf("vendors.%s.status_map.%s.state: must be one of '%s', got '%s'", name, status, strings.Join(allStateNames(), " | "), o.State))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// validateVendors - check comma separated vendor list
+func validateVendors(list string) error {
+	for _, name := range strings.Split(list, ",") {
+		if _, ok := vendorDefs[name]; !ok && name != autoVendor {
+			return fmt.Errorf("unknown vendor '%s'", name)
+		}
+	}
+
+	return nil
+}
+
+// Vendor - settings for vendor 'name' with defaults applied
+func (c Config) Vendor(name string) VendorConfig {
+	vc := c.Vendors[name]
+
+	if len(vc.Binary) == 0 {
+		vc.Binary = vendorDefs[name].binary
+	}
+
+	if len(vc.Root) == 0 && vendorDefs[name].files {
+		vc.Root = defaultFilesRoot
+	}
+
+	if vc.Timeout == 0 {
+		vc.Timeout = c.Timeout
+	}
+
+	return vc
+}
+
+// VendorExecPath - tool binary of vendor 'name', root directory for vendors reading files
+func (c Config) VendorExecPath(name string) string {
+	vc := c.Vendor(name)
+	if vendorDefs[name].files {
+		return vc.Root
+	}
+
+	return vc.Binary
+}
+
+// MapStatus - replace vendor status and its health with configured override
+func (vc VendorConfig) MapStatus(status string, health Health) (string, Health) {
+	o, ok := vc.StatusMap[status]
+	if !ok {
+		return status, health
+	}
+
+	state := o.State
+	if len(state) == 0 {
+		state = o.Status
+	}
+
+	if s, ok := ParseState(state); ok {
+		health = NewHealth(s)
+	}
+
+	return o.Status, health
+}
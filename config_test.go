@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	data, err := os.ReadFile("config.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if c.DefaultVendor != "megacli" {
+		t.Errorf("got default vendor '%s', want 'megacli'", c.DefaultVendor)
+	}
+
+	megacli := c.Vendor("megacli")
+	if megacli.Binary != "/opt/MegaRAID/MegaCli/MegaCli64" || megacli.Timeout != 20 {
+		t.Errorf("unexpected megacli config: %+v", megacli)
+	}
+
+	if s, h := megacli.MapStatus("Online, Spun Up", NewHealth(StateUnknown)); s != "OK" || h.StateCode != StateOK {
+		t.Errorf("got mapped status '%s' %+v, want 'OK' ok", s, h)
+	}
+
+	if s, h := megacli.MapStatus("Failed", NewHealth(StateCritical)); s != "Failed" || h.StateCode != StateCritical {
+		t.Errorf("got mapped status '%s' %+v, want 'Failed' critical", s, h)
+	}
+
+	adaptec := c.Vendor("adaptec")
+	if !reflect.DeepEqual(adaptec.Args, []string{"nologs"}) || adaptec.Timeout != 10 {
+		t.Errorf("unexpected adaptec config: %+v", adaptec)
+	}
+
+	if c.Cache.Dir != "/run/raidstat" || c.Cache.TTL != 30 {
+		t.Errorf("unexpected cache config: %+v", c.Cache)
+	}
+
+	hp := c.Vendor("hp")
+	if hp.Binary != "ssacli" || hp.Timeout != 10 {
+		t.Errorf("unexpected hp defaults: %+v", hp)
+	}
+
+	if mdraid := c.Vendor("mdraid"); mdraid.Root != "/" || len(mdraid.Binary) != 0 {
+		t.Errorf("unexpected mdraid defaults: %+v", mdraid)
+	}
+}
+
+func TestVendorRoot(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("testdata", "mdraid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ParseConfig([]byte(`{"vendors": {"mdraid": {"root": "` + root + `"}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	v, err := NewVendorWithRunner("mdraid", c, FileRunner{}, FixtureRunner{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ids, err := v.GetLogicalDrivesIDs("0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := []string{"md127", "md1", "md0"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got logical drives %v, want %v", ids, want)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"default_vendor": "dell"}`, "default_vendor: unknown vendor 'dell'"},
+		{`{"timeout": 0}`, "timeout: must be positive"},
+		{`{"vendors": {"lsi": {}}}`, "vendors: unknown vendor 'lsi'"},
+		{`{"vendors": {"hp": {"timeout": -1}}}`, "vendors.hp.timeout: must not be negative"},
+		{`{"vendors": {"mdraid": {"binary": "/srv/host"}}}`, "vendors.mdraid.binary: vendor reads files and has no tool, use 'root'"},
+		{`{"vendors": {"hp": {"root": "/srv/host"}}}`, "vendors.hp.root: vendor runs tool 'ssacli', use 'binary'"},
+		{`{"vendors": {"mdraid": {"root": "srv/host"}}}`, "vendors.mdraid.root: must be absolute path"},
+		{`{"vendors": {"hp": {"path": "/usr/sbin/ssacli"}}}`, "unknown field"},
+		{`{"timeout": "10"}`, "cannot unmarshal"},
+		{`{"cache": {"ttl": -1}}`, "cache.ttl: must not be negative"},
+		{`{"cache": {"ttl": 30, "dir": "run/raidstat"}}`, "cache.dir: must be absolute path"},
+		{`{"vendors": {"hp": {"status_map": {"Failed": {"status": "OK", "state": "fine"}}}}}`, "vendors.hp.status_map.Failed.state: must be one of 'ok | warning | critical | unknown'"},
+		{`{"vendors": {"hp": {"status_map": {"Failed": {"status": "OK", "code": 0}}}}}`, "unknown field"},
+		{`{"log": {"level": "verbose"}}`, "log: unknown log level 'verbose'"},
+		{`{"log": {"output": "raidstat.log"}}`, "log: output must be 'stderr', 'syslog' or absolute file path"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseConfig([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("config %s: got error '%v', want '%s'", tt.data, err, tt.err)
+		}
+	}
+}
+
+func TestStatusMapVendor(t *testing.T) {
+	c, err := ParseConfig([]byte(`{"vendors": {"zfs": {"status_map": {
+		"DEGRADED": "OK",
+		"ONLINE": {"status": "ONLINE", "state": "warning"},
+		"FAULTED": "Replaced"
+	}}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tt := findVendorTest(t, "zfs")
+
+	v, err := NewVendorWithRunner("zfs", c, tt.fixtureRunner(), FixtureRunner{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		controllerID string
+		deviceID     string
+		status       string
+		state        State
+	}{
+		// status named like state sets state
+		{"tank", "", "OK", StateOK},
+		// state named by override
+		{"rpool", "", "ONLINE", StateWarning},
+		// other status keeps state of vendor status
+		{"tank", "wwn-0x5000c500a1b2c305", "Replaced", StateCritical},
+	}
+
+	for _, tt := range tests {
+		var (
+			status string
+			health Health
+		)
+
+		if len(tt.deviceID) == 0 {
+			ct, err := v.GetControllerStatus(tt.controllerID)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			status, health = ct.Status, ct.Health
+		} else {
+			pd, err := v.GetPDStatus(tt.controllerID, tt.deviceID)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			status, health = pd.Status, pd.Health
+		}
+
+		if status != tt.status || health != NewHealth(tt.state) {
+			t.Errorf("%s %s: got status '%s' state_code %d, want '%s' state_code %d", tt.controllerID, tt.deviceID, status, health.StateCode, tt.status, tt.state)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "raidstat.json")
+
+	if err := os.WriteFile(path, []byte(`{"default_vendor": "hp"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(configEnv, path)
+
+	c, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if c.DefaultVendor != "hp" {
+		t.Errorf("config from $%s not loaded: %+v", configEnv, c)
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for missing explicit config, got nil")
+	}
+}
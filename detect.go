@@ -0,0 +1,301 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	autoVendor       = "auto"
+	defaultSysfsRoot = "/sys"
+)
+
+// driverVendors - kernel drivers and vendors whose tools manage bound controllers
+var driverVendors = map[string][]string{
+	"aacraid":      {"adaptec"},
+	"megaraid_sas": {"storcli", "megacli"},
+	"hpsa":         {"hp"},
+	"smartpqi":     {"hp", "adaptec"},
+	"mvsas":        {"marvell"},
+	"3w-9xxx":      {"3ware"},
+	"3w-sas":       {"3ware"},
+	"arcmsr":       {"areca"},
+	"nvme":         {"nvme"},
+}
+
+// pciVendors - PCI vendor IDs of storage controllers without known driver bound
+var pciVendors = map[string][]string{
+	"0x9005":         {"adaptec"},
+	"0x103c":         {"hp"},
+	"0x1590":         {"hp"},
+	pciVendorLSI:     {"storcli", "megacli", "sas2ircu", "sas3ircu"},
+	pciVendorMarvell: {"marvell"},
+	"0x13c1":         {"3ware"},
+	"0x17d3":         {"areca"},
+}
+
+// moduleVendors - kernel modules and vendors managing storage without own controller
+var moduleVendors = map[string]string{
+	"zfs": "zfs",
+}
+
+// supersededVendors - vendors skipped when vendor managing same controllers with newer or OEM tool is available
+var supersededVendors = map[string][]string{
+	"megacli": {"storcli", "perccli"},
+	"storcli": {"perccli"},
+}
+
+const (
+	pciVendorLSI         = "0x1000"
+	pciVendorDell        = "0x1028"
+	pciDeviceLSISAS3     = 0x0090
+	pciVendorMarvell     = "0x1b4b"
+	pciClassStorage      = "0x01"
+	pciClassStorageRAID  = "0x0104"
+	pciClassStorageSAS   = "0x0107"
+	pciDriverGenericSATA = "ahci"
+)
+
+// PCIDevice - storage controller found in sysfs
+type PCIDevice struct {
+	Address     string
+	VendorID    string
+	DeviceID    string
+	SubVendorID string
+	Class       string
+	Driver      string
+}
+
+// Detector - finds RAID vendors present on the host
+type Detector struct {
+	SysfsRoot string
+	LookPath  func(string) (string, error)
+}
+
+// NewDetector - create detector for sysfs mounted at 'sysfsRoot'
+func NewDetector(sysfsRoot string) Detector {
+	if len(sysfsRoot) == 0 {
+		sysfsRoot = defaultSysfsRoot
+	}
+
+	return Detector{SysfsRoot: sysfsRoot, LookPath: exec.LookPath}
+}
+
+// StorageDevices - list PCI mass storage controllers
+func (d Detector) StorageDevices() ([]PCIDevice, error) {
+	devicesPath := filepath.Join(d.SysfsRoot, "bus", "pci", "devices")
+
+	entries, err := os.ReadDir(devicesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []PCIDevice
+
+	for _, e := range entries {
+		devicePath := filepath.Join(devicesPath, e.Name())
+
+		class := readSysfsValue(filepath.Join(devicePath, "class"))
+		if !strings.HasPrefix(class, pciClassStorage) {
+			continue
+		}
+
+		var driver string
+		if link, err := os.Readlink(filepath.Join(devicePath, "driver")); err == nil {
+			driver = filepath.Base(link)
+		}
+
+		data = append(data, PCIDevice{
+			Address:     e.Name(),
+			VendorID:    readSysfsValue(filepath.Join(devicePath, "vendor")),
+			DeviceID:    readSysfsValue(filepath.Join(devicePath, "device")),
+			SubVendorID: readSysfsValue(filepath.Join(devicePath, "subsystem_vendor")),
+			Class:       class,
+			Driver:      driver,
+		})
+	}
+
+	return data, nil
+}
+
+// Candidates - vendors that may manage PCI device
+func (p PCIDevice) Candidates() []string {
+	// Marvell RAID chips used in M.2 kits are bound to generic AHCI driver
+	if p.Driver == pciDriverGenericSATA {
+		if p.VendorID == pciVendorMarvell {
+			return []string{"marvell"}
+		}
+		return nil
+	}
+
+	// Dell PERC is LSI MegaRAID chip with Dell subsystem, managed by perccli
+	if p.VendorID == pciVendorLSI && p.SubVendorID == pciVendorDell && (p.Driver == "megaraid_sas" || len(p.Driver) == 0) {
+		return []string{"perccli", "storcli", "megacli"}
+	}
+
+	// mpt2sas and mpt3sas both drive SAS2 and SAS3 chips, each generation has its own tool
+	if p.Driver == "mpt2sas" || p.Driver == "mpt3sas" {
+		return []string{p.ircuVendor()}
+	}
+
+	if v, ok := driverVendors[p.Driver]; ok {
+		return v
+	}
+
+	if len(p.Driver) != 0 {
+		return nil
+	}
+
+	// no driver bound, guess by PCI vendor and class
+	switch {
+	case p.VendorID == pciVendorLSI && strings.HasPrefix(p.Class, pciClassStorageRAID):
+		return []string{"storcli", "megacli"}
+	case p.VendorID == pciVendorLSI && strings.HasPrefix(p.Class, pciClassStorageSAS):
+		return []string{p.ircuVendor()}
+	}
+
+	return pciVendors[p.VendorID]
+}
+
+// ircuVendor - sas3ircu for LSI SAS3 chips (SAS3004 and newer), sas2ircu for older ones
+func (p PCIDevice) ircuVendor() string {
+	if id, err := strconv.ParseUint(strings.TrimPrefix(p.DeviceID, "0x"), 16, 16); err == nil && id >= pciDeviceLSISAS3 {
+		return "sas3ircu"
+	}
+
+	return "sas2ircu"
+}
+
+// SoftwareRAIDArrays - md arrays present in sysfs
+func (d Detector) SoftwareRAIDArrays() []string {
+	paths, _ := filepath.Glob(filepath.Join(d.SysfsRoot, "block", "md*", "md"))
+
+	var data []string
+	for _, p := range paths {
+		data = append(data, filepath.Base(filepath.Dir(p)))
+	}
+
+	return data
+}
+
+// Detect - vendors with controllers present in sysfs and tool binary available, in 'vendors' order,
+// vendor is skipped when every controller it could manage is managed by vendor superseding it
+func (d Detector) Detect(c Config) ([]string, error) {
+	devices, err := d.StorageDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	// candidates of every controller, vendors without controllers have their own list
+	var candidates [][]string
+
+	found := map[string]bool{}
+	for _, p := range devices {
+		logger.Debugf("storage controller %s vendor %s device %s subsystem vendor %s class %s driver '%s', candidates: %s", p.Address, p.VendorID, p.DeviceID, p.SubVendorID, p.Class, p.Driver, strings.Join(p.Candidates(), ", "))
+
+		candidates = append(candidates, p.Candidates())
+		for _, v := range p.Candidates() {
+			found[v] = true
+		}
+	}
+
+	if arrays := d.SoftwareRAIDArrays(); len(arrays) != 0 {
+		logger.Debugf("md arrays: %s", strings.Join(arrays, ", "))
+		candidates = append(candidates, []string{"mdraid"})
+		found["mdraid"] = true
+	}
+
+	for m, v := range moduleVendors {
+		if _, err := os.Stat(filepath.Join(d.SysfsRoot, "module", m)); err == nil {
+			logger.Debugf("kernel module %s is loaded", m)
+			candidates = append(candidates, []string{v})
+			found[v] = true
+		}
+	}
+
+	available := map[string]bool{}
+
+	for _, v := range vendors {
+		if !found[v] {
+			continue
+		}
+
+		// vendors without tool read kernel files
+		if vendorDefs[v].files {
+			available[v] = true
+			continue
+		}
+
+		if _, err := d.LookPath(c.Vendor(v).Binary); err != nil {
+			logger.Infof("vendor %s detected, but its tool is not available: %s", v, err)
+			continue
+		}
+
+		available[v] = true
+	}
+
+	used := map[string]bool{}
+	superseded := map[string]string{}
+
+	for _, list := range candidates {
+		for _, v := range list {
+			if !available[v] {
+				continue
+			}
+
+			if s := supersededBy(v, list, available); len(s) != 0 {
+				superseded[v] = s
+				continue
+			}
+
+			used[v] = true
+		}
+	}
+
+	var data []string
+
+	for _, v := range vendors {
+		if !available[v] {
+			continue
+		}
+
+		if !used[v] {
+			logger.Infof("vendor %s detected, but %s is used for its controllers", v, superseded[v])
+			continue
+		}
+
+		data = append(data, v)
+	}
+
+	return data, nil
+}
+
+// supersededBy - available vendor from controller 'candidates' replacing 'vendor'
+func supersededBy(vendor string, candidates []string, available map[string]bool) string {
+	for _, s := range supersededVendors[vendor] {
+		if !available[s] {
+			continue
+		}
+
+		for _, c := range candidates {
+			if c == s {
+				return s
+			}
+		}
+	}
+
+	return ""
+}
+
+// readSysfsValue - read single value sysfs attribute
+func readSysfsValue(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// makeSysfsDevice - create fake PCI device in sysfs tree at 'root'
+func makeSysfsDevice(t *testing.T, root string, p PCIDevice) {
+	t.Helper()
+
+	devicePath := filepath.Join(root, "bus", "pci", "devices", p.Address)
+	if err := os.MkdirAll(devicePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, value := range map[string]string{"vendor": p.VendorID, "device": p.DeviceID, "subsystem_vendor": p.SubVendorID, "class": p.Class} {
+		if err := os.WriteFile(filepath.Join(devicePath, name), []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(p.Driver) != 0 {
+		driverPath := filepath.Join(root, "bus", "pci", "drivers", p.Driver)
+		if err := os.MkdirAll(driverPath, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Symlink(driverPath, filepath.Join(devicePath, "driver")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// fakeLookPath - LookPath finding only binaries from 'available'
+func fakeLookPath(available ...string) func(string) (string, error) {
+	return func(file string) (string, error) {
+		for _, v := range available {
+			if v == file {
+				return "/usr/sbin/" + file, nil
+			}
+		}
+
+		return "", errors.New("executable file not found")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	root := t.TempDir()
+
+	for _, p := range []PCIDevice{
+		{Address: "0000:00:1f.2", VendorID: "0x8086", DeviceID: "0xa102", Class: "0x010601", Driver: "ahci"},
+		{Address: "0000:02:00.0", VendorID: "0x103c", DeviceID: "0x323a", Class: "0x010400", Driver: "hpsa"},
+		{Address: "0000:03:00.0", VendorID: "0x1000", DeviceID: "0x0072", Class: "0x010700", Driver: "mpt3sas"},
+		{Address: "0000:04:00.0", VendorID: "0x1b4b", DeviceID: "0x9230", Class: "0x010601", Driver: "ahci"},
+		{Address: "0000:05:00.0", VendorID: "0x8086", DeviceID: "0x1521", Class: "0x020000", Driver: "igb"},
+	} {
+		makeSysfsDevice(t, root, p)
+	}
+
+	c, err := ParseConfig([]byte(`{"vendors": {"sas2ircu": {"binary": "/opt/lsi/sas2ircu"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		binaries []string
+		want     []string
+	}{
+		{[]string{"ssacli", "/opt/lsi/sas2ircu", "mvcli", "arcconf", "megacli"}, []string{"hp", "marvell", "sas2ircu"}},
+		{[]string{"ssacli", "sas2ircu"}, []string{"hp"}},
+		{nil, nil},
+	}
+
+	for _, tt := range tests {
+		d := Detector{SysfsRoot: root, LookPath: fakeLookPath(tt.binaries...)}
+
+		got, err := d.Detect(c)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("binaries %q: got vendors %q, want %q", tt.binaries, got, tt.want)
+		}
+	}
+}
+
+func TestDetectSoftwareRAID(t *testing.T) {
+	root := t.TempDir()
+	makeSysfsDevice(t, root, PCIDevice{Address: "0000:02:00.0", VendorID: "0x103c", DeviceID: "0x323a", Class: "0x010400", Driver: "hpsa"})
+
+	d := Detector{SysfsRoot: root, LookPath: fakeLookPath("ssacli")}
+
+	got, err := d.Detect(defaultConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := []string{"hp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("without md arrays got vendors %q, want %q", got, want)
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, "block", "md0", "md"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = d.Detect(defaultConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := []string{"hp", "mdraid"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with md arrays got vendors %q, want %q", got, want)
+	}
+}
+
+func TestDetectZFS(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "bus", "pci", "devices"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, "module", "zfs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		binaries []string
+		want     []string
+	}{
+		{[]string{"zpool"}, []string{"zfs"}},
+		{nil, nil},
+	}
+
+	for _, tt := range tests {
+		d := Detector{SysfsRoot: root, LookPath: fakeLookPath(tt.binaries...)}
+
+		got, err := d.Detect(defaultConfig())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("binaries %q: got vendors %q, want %q", tt.binaries, got, tt.want)
+		}
+	}
+}
+
+func TestDetectSuperseded(t *testing.T) {
+	root := t.TempDir()
+	makeSysfsDevice(t, root, PCIDevice{Address: "0000:3b:00.0", VendorID: "0x1000", DeviceID: "0x005d", SubVendorID: "0x1000", Class: "0x010400", Driver: "megaraid_sas"})
+
+	dell := t.TempDir()
+	makeSysfsDevice(t, dell, PCIDevice{Address: "0000:18:00.0", VendorID: "0x1000", DeviceID: "0x005d", SubVendorID: "0x1028", Class: "0x010400", Driver: "megaraid_sas"})
+
+	// Dell PERC and LSI MegaRAID in one host, perccli manages Dell cards only
+	mixed := t.TempDir()
+	makeSysfsDevice(t, mixed, PCIDevice{Address: "0000:18:00.0", VendorID: "0x1000", DeviceID: "0x005d", SubVendorID: "0x1028", Class: "0x010400", Driver: "megaraid_sas"})
+	makeSysfsDevice(t, mixed, PCIDevice{Address: "0000:3b:00.0", VendorID: "0x1000", DeviceID: "0x005d", SubVendorID: "0x1000", Class: "0x010400", Driver: "megaraid_sas"})
+
+	tests := []struct {
+		root     string
+		binaries []string
+		want     []string
+	}{
+		{root, []string{"storcli64", "megacli"}, []string{"storcli"}},
+		{root, []string{"megacli"}, []string{"megacli"}},
+		{root, []string{"storcli64", "perccli64"}, []string{"storcli"}},
+		{dell, []string{"storcli64", "perccli64", "megacli"}, []string{"perccli"}},
+		{dell, []string{"storcli64", "megacli"}, []string{"storcli"}},
+		{mixed, []string{"storcli64", "perccli64"}, []string{"storcli", "perccli"}},
+		{mixed, []string{"storcli64", "perccli64", "megacli"}, []string{"storcli", "perccli"}},
+		{mixed, []string{"perccli64", "megacli"}, []string{"megacli", "perccli"}},
+	}
+
+	for _, tt := range tests {
+		d := Detector{SysfsRoot: tt.root, LookPath: fakeLookPath(tt.binaries...)}
+
+		got, err := d.Detect(defaultConfig())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("binaries %q: got vendors %q, want %q", tt.binaries, got, tt.want)
+		}
+	}
+}
+
+func TestPCIDeviceCandidates(t *testing.T) {
+	tests := []struct {
+		device PCIDevice
+		want   []string
+	}{
+		{PCIDevice{VendorID: "0x9005", Class: "0x010400", Driver: "aacraid"}, []string{"adaptec"}},
+		{PCIDevice{VendorID: "0x1000", Class: "0x010400", Driver: "megaraid_sas"}, []string{"storcli", "megacli"}},
+		{PCIDevice{VendorID: "0x9005", Class: "0x010700", Driver: "smartpqi"}, []string{"hp", "adaptec"}},
+		{PCIDevice{VendorID: "0x1000", Class: "0x010400"}, []string{"storcli", "megacli"}},
+		{PCIDevice{VendorID: "0x1000", SubVendorID: "0x1028", Class: "0x010400", Driver: "megaraid_sas"}, []string{"perccli", "storcli", "megacli"}},
+		{PCIDevice{VendorID: "0x1000", Class: "0x010700"}, []string{"sas2ircu"}},
+		{PCIDevice{VendorID: "0x1000", DeviceID: "0x0072", Class: "0x010700", Driver: "mpt3sas"}, []string{"sas2ircu"}},
+		{PCIDevice{VendorID: "0x1000", DeviceID: "0x0097", Class: "0x010700", Driver: "mpt3sas"}, []string{"sas3ircu"}},
+		{PCIDevice{VendorID: "0x1000", DeviceID: "0x0097", Class: "0x010700"}, []string{"sas3ircu"}},
+		{PCIDevice{VendorID: "0x13c1", DeviceID: "0x1004", Class: "0x010400", Driver: "3w-9xxx"}, []string{"3ware"}},
+		{PCIDevice{VendorID: "0x13c1", DeviceID: "0x1010", Class: "0x010400"}, []string{"3ware"}},
+		{PCIDevice{VendorID: "0x17d3", DeviceID: "0x188a", Class: "0x010400", Driver: "arcmsr"}, []string{"areca"}},
+		{PCIDevice{VendorID: "0x144d", DeviceID: "0xa80a", Class: "0x010802", Driver: "nvme"}, []string{"nvme"}},
+		{PCIDevice{VendorID: "0x8086", Class: "0x010601", Driver: "ahci"}, nil},
+		{PCIDevice{VendorID: "0x1000", Class: "0x010700", Driver: "vfio-pci"}, nil},
+	}
+
+	for _, tt := range tests {
+		if got := tt.device.Candidates(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("device %+v: got %q, want %q", tt.device, got, tt.want)
+		}
+	}
+}
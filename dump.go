@@ -0,0 +1,88 @@
+package main
+
+import "strings"
+
+// Dump - statuses of all controllers with their logical and physical drives, keyed by ID
+type Dump struct {
+	Controllers map[string]DumpController `json:"controllers"`
+}
+
+// DumpController - controller status with its drives, 'Error' is set when status is unavailable
+type DumpController struct {
+	Controller
+	Error          string                       `json:"error,omitempty"`
+	LogicalDrives  map[string]DumpLogicalDrive  `json:"logicaldrives"`
+	PhysicalDrives map[string]DumpPhysicalDrive `json:"physicaldrives"`
+}
+
+// DumpLogicalDrive - logical drive status, 'Error' is set when status is unavailable
+type DumpLogicalDrive struct {
+	LogicalDrive
+	Error string `json:"error,omitempty"`
+}
+
+// DumpPhysicalDrive - physical drive status, 'Error' is set when status is unavailable
+type DumpPhysicalDrive struct {
+	PhysicalDrive
+	Error string `json:"error,omitempty"`
+}
+
+// CollectDump - walk all controllers and drives once, failures of single devices are recorded in dump
+func CollectDump(v Vendor) (Dump, error) {
+	d := Dump{Controllers: map[string]DumpController{}}
+
+	controllersIDs, err := v.GetControllersIDs()
+	if err != nil {
+		return d, err
+	}
+
+	for _, ctID := range controllersIDs {
+		ct := DumpController{
+			LogicalDrives:  map[string]DumpLogicalDrive{},
+			PhysicalDrives: map[string]DumpPhysicalDrive{},
+		}
+
+		var errs []string
+
+		if ct.Controller, err = v.GetControllerStatus(ctID); err != nil {
+			ct.Health = NewHealth(StateUnknown)
+			errs = append(errs, err.Error())
+		}
+
+		if logicalDrivesIDs, err := v.GetLogicalDrivesIDs(ctID); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			for _, ldID := range logicalDrivesIDs {
+				var ld DumpLogicalDrive
+				if ld.LogicalDrive, err = v.GetLDStatus(ctID, ldID); err != nil {
+					ld.Health = NewHealth(StateUnknown)
+					ld.Error = err.Error()
+				}
+
+				ct.LogicalDrives[ldID] = ld
+			}
+		}
+
+		if physicalDrivesIDs, err := v.GetPhysicalDrivesIDs(ctID); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			for _, pdID := range physicalDrivesIDs {
+				var pd DumpPhysicalDrive
+				if pd.PhysicalDrive, err = v.GetPDStatus(ctID, pdID); err != nil {
+					pd.Health = NewHealth(StateUnknown)
+					pd.Error = err.Error()
+				}
+
+				ct.PhysicalDrives[pdID] = pd
+			}
+		}
+
+		if len(errs) > 0 {
+			ct.Error = strings.Join(errs, "; ")
+		}
+
+		d.Controllers[ctID] = ct
+	}
+
+	return d, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollectDump(t *testing.T) {
+	d, err := CollectDump(fixtureVendor(t, "megacli"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		t.Fatalf("error marshalling JSON: %s", err)
+	}
+	got = append(got, '\n')
+
+	compareGolden(t, filepath.Join("testdata", "golden", "dump-megacli.json"), got)
+}
+
+func TestCollectDumpDeviceError(t *testing.T) {
+	tt := findVendorTest(t, "megacli")
+
+	files := map[string]string{}
+	for key, file := range tt.commands {
+		files[key] = file
+	}
+	delete(files, "-pdInfo -PhysDrv[252:1] -a0 -NoLog")
+
+	d, err := CollectDump(tt.newVendor(tt.execPath, FixtureRunner{Dir: tt.fixtures, Files: files}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ct := d.Controllers["0"]
+
+	pd := ct.PhysicalDrives["252:1"]
+	if pd.StateCode != StateUnknown || !strings.Contains(pd.Error, "no fixture for command 'megacli -pdInfo -PhysDrv[252:1] -a0 -NoLog'") {
+		t.Errorf("got failing drive %+v, want unknown state with command error", pd)
+	}
+
+	if ok := ct.PhysicalDrives["252:2"]; ok.StateCode != StateOK || len(ok.Error) != 0 {
+		t.Errorf("got drive %+v, want ok drive unaffected by failing one", ok)
+	}
+
+	if len(ct.Error) != 0 || ct.StateCode != StateOK {
+		t.Errorf("got controller state %s error '%s', want ok without error", ct.State, ct.Error)
+	}
+}
+
+func TestCollectDumpControllersError(t *testing.T) {
+	if _, err := CollectDump(NewHPVendor("ssacli", FixtureRunner{})); err == nil {
+		t.Error("expected error when controllers are not available, got nil")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// error codes reported as 'error.code' in error json
+const (
+	ErrorCodeGeneric       = "error"
+	ErrorCodeUsage         = "usage_error"
+	ErrorCodeToolNotFound  = "tool_not_found"
+	ErrorCodeTimeout       = "timeout"
+	ErrorCodeParse         = "parse_error"
+	ErrorCodeUnknownDevice = "unknown_device"
+)
+
+// errorExitCodes - process exit code for error code, lets alerting tell broken monitoring from failed disk
+var errorExitCodes = map[string]int{
+	ErrorCodeGeneric:       1,
+	ErrorCodeUsage:         2,
+	ErrorCodeToolNotFound:  3,
+	ErrorCodeTimeout:       4,
+	ErrorCodeParse:         5,
+	ErrorCodeUnknownDevice: 6,
+}
+
+// CommandError - error with code and RAID tool command it came from
+type CommandError struct {
+	Code    string
+	Command string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// NewParseError - 'field' was not found in output of command
+func NewParseError(field string, execPath string, args ...string) error {
+	command := commandLine(execPath, args)
+	return &CommandError{
+		Code:    ErrorCodeParse,
+		Command: command,
+		Err:     fmt.Errorf("%s not found in output of command '%s'", field, command),
+	}
+}
+
+// NewUnknownDeviceError - controller or drive id doesn't exist or is malformed
+func NewUnknownDeviceError(format string, a ...interface{}) error {
+	return &CommandError{Code: ErrorCodeUnknownDevice, Err: fmt.Errorf(format, a...)}
+}
+
+// NewUsageError - command line option is invalid
+func NewUsageError(format string, a ...interface{}) error {
+	return &CommandError{Code: ErrorCodeUsage, Err: fmt.Errorf(format, a...)}
+}
+
+// ErrorReport - error json written instead of status on failure
+type ErrorReport struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Vendor  string `json:"vendor"`
+	Command string `json:"command"`
+}
+
+// NewErrorReport - error json for 'err' of 'vendor', errors without code are ErrorCodeGeneric
+func NewErrorReport(err error, vendor string) ErrorReport {
+	var r ErrorReport
+
+	r.Error.Code = ErrorCodeGeneric
+	r.Error.Message = err.Error()
+	r.Vendor = vendor
+
+	var cErr *CommandError
+	if errors.As(err, &cErr) {
+		r.Error.Code = cErr.Code
+		r.Command = cErr.Command
+	}
+
+	return r
+}
+
+// ExitCode - process exit code for error report
+func (r ErrorReport) ExitCode() int {
+	if code, ok := errorExitCodes[r.Error.Code]; ok {
+		return code
+	}
+
+	return errorExitCodes[ErrorCodeGeneric]
+}
+
+// commandLine - command as it would be typed in shell
+func commandLine(execPath string, args []string) string {
+	return strings.Join(append([]string{execPath}, args...), " ")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestGetCommandOutputErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  int
+		execPath string
+		args     []string
+		code     string
+	}{
+		{"missing in PATH", 1, "raidstat-missing-tool", nil, ErrorCodeToolNotFound},
+		{"missing path", 1, "/nonexistent/raidstat-tool", nil, ErrorCodeToolNotFound},
+		{"timeout", 1, "sleep", []string{"5"}, ErrorCodeTimeout},
+		{"failed", 1, "false", nil, ErrorCodeGeneric},
+	}
+
+	for _, tt := range tests {
+		_, err := GetCommandOutput(tt.timeout, tt.execPath, tt.args...)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
+			continue
+		}
+
+		r := NewErrorReport(err, "hp")
+		if r.Error.Code != tt.code {
+			t.Errorf("%s: got code %q, want %q (%s)", tt.name, r.Error.Code, tt.code, err)
+		}
+
+		if want := commandLine(tt.execPath, tt.args); r.Command != want {
+			t.Errorf("%s: got command %q, want %q", tt.name, r.Command, want)
+		}
+	}
+}
+
+func TestVendorErrorCodes(t *testing.T) {
+	r := FixtureRunner{
+		Dir: "testdata/sas2ircu",
+		Files: map[string]string{
+			"0 display": "display.txt",
+			"1 display": "",
+		},
+	}
+	v := NewSAS2IrcuVendor("sas2ircu", r)
+
+	tests := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{"unknown logical drive", func() error { _, err := v.GetLDStatus("0", "99"); return err }(), ErrorCodeUnknownDevice},
+		{"malformed drive id", func() error { _, err := v.GetPDStatus("0", "1"); return err }(), ErrorCodeUnknownDevice},
+		{"unknown physical drive", func() error { _, err := v.GetPDStatus("0", "9:9"); return err }(), ErrorCodeUnknownDevice},
+		{"empty output", func() error { _, err := v.GetControllerStatus("1"); return err }(), ErrorCodeParse},
+		{"missing fixture", func() error { _, err := v.GetControllerStatus("2"); return err }(), ErrorCodeGeneric},
+	}
+
+	for _, tt := range tests {
+		if tt.err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
+			continue
+		}
+
+		if got := NewErrorReport(tt.err, "sas2ircu").Error.Code; got != tt.code {
+			t.Errorf("%s: got code %q, want %q (%s)", tt.name, got, tt.code, tt.err)
+		}
+	}
+}
+
+func TestErrorReport(t *testing.T) {
+	err := fmt.Errorf("sas2ircu: %w", NewParseError("controller type", "sas2ircu", "0", "display"))
+
+	r := NewErrorReport(err, "sas2ircu")
+	if r.ExitCode() != 5 {
+		t.Errorf("got exit code %d, want 5", r.ExitCode())
+	}
+
+	data, jErr := json.Marshal(r)
+	if jErr != nil {
+		t.Fatal(jErr)
+	}
+
+	want := `{"error":{"code":"parse_error","message":"sas2ircu: controller type not found in output of command 'sas2ircu 0 display'"},"vendor":"sas2ircu","command":"sas2ircu 0 display"}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	if r := NewErrorReport(NewUsageError("vendors must be one of 'hp', got '%s'", "hpp"), "hpp"); r.Error.Code != ErrorCodeUsage || r.ExitCode() != 2 {
+		t.Errorf("got code %q exit code %d for usage error, want %q 2", r.Error.Code, r.ExitCode(), ErrorCodeUsage)
+	}
+
+	if code := NewErrorReport(errors.New("boom"), "hp").ExitCode(); code != 1 {
+		t.Errorf("got exit code %d for generic error, want 1", code)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	metricsPath          = "/metrics"
+	defaultScrapeTimeout = 60
+)
+
+// Exporter - serves metrics of vendors over HTTP, scrapes arriving while collection runs wait for it
+// instead of running vendor tools again, so tools never run in parallel
+type Exporter struct {
+	Names   []string
+	Vendors map[string]Vendor
+	Timeout time.Duration
+
+	mu        sync.Mutex
+	inflight  *metricsCollection
+	abandoned *metricsCollection
+}
+
+// metricsCollection - running collection, 'done' is closed when 'families' are ready at 'finished'
+type metricsCollection struct {
+	done     chan struct{}
+	families []MetricFamily
+	finished time.Time
+}
+
+// Collect - metrics of running collection or of new one, waiting is interrupted when 'ctx' is done,
+// collection then keeps running and next scrape gets its result when it finished less than Timeout ago
+func (e *Exporter) Collect(ctx context.Context) ([]MetricFamily, error) {
+	e.mu.Lock()
+	if a := e.abandoned; a != nil && !a.finished.IsZero() {
+		e.abandoned = nil
+
+		if time.Since(a.finished) < e.Timeout {
+			e.mu.Unlock()
+			logger.Debugf("scrape gets result of collection finished after previous scrape timed out")
+			return a.families, nil
+		}
+	}
+
+	c := e.inflight
+	if c == nil {
+		c = &metricsCollection{done: make(chan struct{})}
+		e.inflight = c
+
+		go func() {
+			c.families = CollectMetrics(e.Names, e.Vendors)
+
+			e.mu.Lock()
+			e.inflight = nil
+			c.finished = time.Now()
+			e.mu.Unlock()
+
+			close(c.done)
+		}()
+	} else {
+		logger.Debugf("scrape waits for running collection")
+	}
+	e.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.families, nil
+	case <-ctx.Done():
+		e.mu.Lock()
+		e.abandoned = c
+		e.mu.Unlock()
+
+		return nil, fmt.Errorf("metrics collection did not finish: %w", ctx.Err())
+	}
+}
+
+// ServeHTTP - write metrics, 503 when collection doesn't finish in scrape timeout
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), e.Timeout)
+	defer cancel()
+
+	families, err := e.Collect(ctx)
+	if err != nil {
+		logger.Errorf("scrape from %s failed: %s", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", metricsContentType)
+	if err := WriteMetrics(w, families); err != nil {
+		logger.Warnf("error writing metrics to %s: %s", r.RemoteAddr, err)
+	}
+}
+
+// NewExporterHandler - metrics on metricsPath and link to them on '/'
+func NewExporterHandler(e *Exporter) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, e)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprintf(w, "<html><head><title>raidstat exporter</title></head><body><a href=\"%s\">Metrics</a></body></html>\n", metricsPath)
+	})
+
+	return mux
+}
+
+// serveMetrics - run exporter on 'listen' address until server fails
+func serveMetrics(listen string, e *Exporter) error {
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           NewExporterHandler(e),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	logger.Infof("serving metrics on %s%s", listen, metricsPath)
+
+	return server.ListenAndServe()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingVendor - vendor without controllers, listing waits for 'release' and is counted
+type blockingVendor struct {
+	Vendor
+	calls   *int32
+	release chan struct{}
+}
+
+func (v blockingVendor) GetControllersIDs() ([]string, error) {
+	atomic.AddInt32(v.calls, 1)
+	<-v.release
+	return []string{}, nil
+}
+
+func TestExporterSharedCollection(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+
+	e := &Exporter{
+		Names:   []string{"megacli"},
+		Vendors: map[string]Vendor{"megacli": blockingVendor{calls: &calls, release: release}},
+		Timeout: 10 * time.Second,
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := e.Collect(context.Background())
+			errs <- err
+		}()
+	}
+
+	// let all scrapes reach running collection
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("vendor tools ran %d times for concurrent scrapes, want 1", calls)
+	}
+}
+
+func TestExporterTimeout(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	defer close(release)
+
+	e := &Exporter{
+		Names:   []string{"megacli"},
+		Vendors: map[string]Vendor{"megacli": blockingVendor{calls: &calls, release: release}},
+		Timeout: 50 * time.Millisecond,
+	}
+
+	server := httptest.NewServer(NewExporterHandler(e))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + metricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestExporterTimedOutResult(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+
+	e := &Exporter{
+		Names:   []string{"megacli"},
+		Vendors: map[string]Vendor{"megacli": blockingVendor{calls: &calls, release: release}},
+		Timeout: 10 * time.Second,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := e.Collect(ctx); err == nil {
+		t.Fatal("expected error when collection doesn't finish, got nil")
+	}
+
+	close(release)
+
+	// wait for abandoned collection to finish
+	for i := 0; i < 100; i++ {
+		e.mu.Lock()
+		running := e.inflight != nil
+		e.mu.Unlock()
+
+		if !running {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := e.Collect(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("vendor tools ran %d times, want result of timed out collection reused", calls)
+	}
+
+	// result is served once, next scrape collects again
+	if _, err := e.Collect(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("vendor tools ran %d times, want 2", calls)
+	}
+}
+
+func TestExporterMetrics(t *testing.T) {
+	e := &Exporter{
+		Names:   []string{"nvme"},
+		Vendors: map[string]Vendor{"nvme": fixtureVendor(t, "nvme")},
+		Timeout: 10 * time.Second,
+	}
+
+	server := httptest.NewServer(NewExporterHandler(e))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + metricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if got := resp.Header.Get("Content-Type"); got != metricsContentType {
+		t.Errorf("got content type '%s', want '%s'", got, metricsContentType)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `raid_collector_success{vendor="nvme"} 1`; !strings.Contains(string(body), want) {
+		t.Errorf("metrics don't contain '%s':\n%s", want, body)
+	}
+
+	resp, err = http.Get(server.URL + "/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got status %d for unknown path, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
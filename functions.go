@@ -1,10 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"io/fs"
 	"os/exec"
 	"regexp"
 	"strings"
@@ -16,72 +18,102 @@ func TrimSpacesLeftAndRight(input string) string {
 	return strings.TrimLeft(strings.TrimRight(input, " "), " ")
 }
 
-// GetCommandOutput - get input data from RAID tool
-func GetCommandOutput(execPath string, args ...string) []byte {
-	timeout := 10
+// GetCommandOutput - get input data from RAID tool, command is killed after 'timeout' seconds
+func GetCommandOutput(timeout int, execPath string, args ...string) ([]byte, error) {
+	return getCommandOutput(timeout, nil, execPath, args...)
+}
+
+// getCommandOutput - get input data from tool, non-zero exit status accepted by 'validExit' is not an error
+func getCommandOutput(timeout int, validExit func(int) bool, execPath string, args ...string) ([]byte, error) {
 	execContext, contextCancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer contextCancel()
 
+	command := commandLine(execPath, args)
+
 	cmd := exec.CommandContext(execContext, execPath, args...)
+	started := time.Now()
 	data, err := cmd.Output()
 
-	if os.Getenv("RAIDSTAT_DEBUG") == "y" {
-		fmt.Printf("Command '%s %s' output is:\n'''\n%s\n'''\n", execPath, strings.Join(args, " "), string(data))
+	if cmd.ProcessState != nil {
+		logger.Debugf("command '%s' finished in %s with exit status %d", command, time.Since(started).Round(time.Millisecond), cmd.ProcessState.ExitCode())
+	} else {
+		logger.Debugf("command '%s' failed to start: %s", command, err)
 	}
 
+	logger.Tracef("command '%s' output:\n%s", command, data)
+
 	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) != 0 {
+			logger.Debugf("command '%s' stderr:\n%s", command, exitErr.Stderr)
+		}
+
 		if execContext.Err() == context.DeadlineExceeded {
-			fmt.Printf("Command '%s' timed out.\n", cmd)
-		} else {
-			fmt.Printf("Error executing command '%s %s': %s\n", execPath, strings.Join(args, " "), err)
+			return nil, &CommandError{Code: ErrorCodeTimeout, Command: command, Err: fmt.Errorf("command '%s' timed out", command)}
 		}
 
-		os.Exit(1)
+		if exitErr != nil && validExit != nil && validExit(exitErr.ExitCode()) {
+			return data, nil
+		}
+
+		code := ErrorCodeGeneric
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			code = ErrorCodeToolNotFound
+		}
+
+		return nil, &CommandError{Code: code, Command: command, Err: fmt.Errorf("error executing command '%s': %w", command, err)}
 	}
 
-	return data
+	return data, nil
 }
 
-// GetRegexpSubmatch - returns string from 1st capture group
-func GetRegexpSubmatch(buf []byte, re string) (data string) {
-	result := regexp.MustCompile(re).FindStringSubmatch(string(buf))
+// FindRegexpSubmatches - returns capture groups of first 'n' matches (all when n < 0), line of every match is logged
+func FindRegexpSubmatches(buf []byte, re string, n int) (data [][]string) {
+	for _, idx := range regexp.MustCompile(re).FindAllSubmatchIndex(buf, n) {
+		groups := make([]string, len(idx)/2)
+		for i := range groups {
+			if idx[2*i] >= 0 {
+				groups[i] = string(buf[idx[2*i]:idx[2*i+1]])
+			}
+		}
 
-	if os.Getenv("RAIDSTAT_DEBUG") == "y" {
-		fmt.Printf("Regexp is '%s'\n", re)
-		if len(result) > 0 {
-			fmt.Printf("Result is '%s'\n", result[0])
+		if logger.Enabled(LogDebug) {
+			line := bytes.Count(buf[:idx[0]], []byte("\n")) + 1
+			logger.Debugf("regexp '%s' matched line %d: '%s'", re, line, strings.SplitN(groups[0], "\n", 2)[0])
 		}
-		fmt.Printf("Input data is:\n'''\n%s\n'''\n", string(buf))
+
+		data = append(data, groups)
 	}
 
-	if len(result) > 0 {
-		data = result[1]
+	if len(data) == 0 {
+		logger.Debugf("regexp '%s' didn't match", re)
 	}
 
 	return
 }
 
-// GetRegexpAllSubmatch - returns strings from all capture groups
-func GetRegexpAllSubmatch(buf []byte, re string) (data []string) {
-	result := regexp.MustCompile(re).FindAllStringSubmatch(string(buf), -1)
+// GetRegexpSubmatch - returns string from 1st capture group
+func GetRegexpSubmatch(buf []byte, re string) (data string) {
+	result := FindRegexpSubmatches(buf, re, 1)
 
-	if os.Getenv("RAIDSTAT_DEBUG") == "y" {
-		fmt.Printf("Regexp is '%s'\n", re)
-		fmt.Printf("Result is '%s'\n", result)
-		fmt.Printf("Input data is:\n'''\n%s\n'''\n", string(buf))
+	if len(result) > 0 {
+		data = result[0][1]
 	}
 
-	if len(result) > 0 {
-		for _, v := range result {
-			data = append(data, v[1])
-		}
+	return
+}
+
+// GetRegexpAllSubmatch - returns strings from all capture groups
+func GetRegexpAllSubmatch(buf []byte, re string) (data []string) {
+	for _, v := range FindRegexpSubmatches(buf, re, -1) {
+		data = append(data, v[1])
 	}
 
 	return
 }
 
 // MarshallJSON - returns json object
-func MarshallJSON(data interface{}, indent int) []byte {
+func MarshallJSON(data interface{}, indent int) ([]byte, error) {
 	var (
 		JSON []byte
 		jErr error
@@ -94,9 +126,8 @@ func MarshallJSON(data interface{}, indent int) []byte {
 	}
 
 	if jErr != nil {
-		fmt.Printf("Error marshalling JSON: %s\n", jErr.Error())
-		os.Exit(1)
+		return nil, fmt.Errorf("error marshalling JSON: %w", jErr)
 	}
 
-	return JSON
+	return append(JSON, "\n"...), nil
 }
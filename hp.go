@@ -4,101 +4,175 @@ import (
 	"fmt"
 )
 
+// ssacli status values to normalized state
+var (
+	hpControllerStates = StateMap{
+		"OK":     StateOK,
+		"Failed": StateCritical,
+	}
+	hpLDStates = StateMap{
+		"OK":                    StateOK,
+		"Recovering":            StateWarning,
+		"Ready for Rebuild":     StateWarning,
+		"Rebuilding":            StateWarning,
+		"Expanding":             StateWarning,
+		"Transforming":          StateWarning,
+		"Interim Recovery Mode": StateCritical,
+		"Failed":                StateCritical,
+		"Wrong Drive Replaced":  StateCritical,
+	}
+	hpPDStates = StateMap{
+		"OK":                 StateOK,
+		"Rebuilding":         StateWarning,
+		"Predictive Failure": StateWarning,
+		"Failed":             StateCritical,
+	}
+)
+
 type HPVendor struct {
 	execPath string
+	runner   Runner
 }
 
 // GetControllersIDs - get number of controllers in the system
-func (v HPVendor) GetControllersIDs() []string {
-	inputData := GetCommandOutput(v.execPath, "ctrl", "all", "show")
-	return GetRegexpAllSubmatch(inputData, "in Slot (.*?)[\\s]")
+func (v HPVendor) GetControllersIDs() ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "ctrl", "all", "show")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "in Slot (.*?)[\\s]"), nil
 }
 
 // GetLogicalDrivesIDs - get number of logical drives for controller with ID 'controllerID'
-func (v HPVendor) GetLogicalDrivesIDs(controllerID string) []string {
-	inputData := GetCommandOutput(v.execPath, "ctrl", fmt.Sprintf("slot=%s", controllerID), "ld", "all", "show")
-	return GetRegexpAllSubmatch(inputData, "logicaldrive (.*?)[\\s]")
+func (v HPVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "ctrl", fmt.Sprintf("slot=%s", controllerID), "ld", "all", "show")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "logicaldrive (.*?)[\\s]"), nil
 }
 
 // GetPhysicalDrivesIDs - get number of physical drives for controller with ID 'controllerID'
-func (v HPVendor) GetPhysicalDrivesIDs(controllerID string) []string {
-	inputData := GetCommandOutput(v.execPath, "ctrl", fmt.Sprintf("slot=%s", controllerID), "pd", "all", "show")
-	return GetRegexpAllSubmatch(inputData, "physicaldrive (.*?)[\\s]")
+func (v HPVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "ctrl", fmt.Sprintf("slot=%s", controllerID), "pd", "all", "show")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "physicaldrive (.*?)[\\s]"), nil
 }
 
 // GetControllerStatus - get controller status
-func (v HPVendor) GetControllerStatus(controllerID string, indent int) []byte {
-	type ReturnData struct {
-		Status        string `json:"status"`
-		Model         string `json:"model"`
-		BatteryStatus string `json:"batterystatus"`
-		CacheStatus   string `json:"cachestatus"`
+func (v HPVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	args := []string{"ctrl", fmt.Sprintf("slot=%s", controllerID), "show", "status"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return Controller{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "ctrl", fmt.Sprintf("slot=%s", controllerID), "show", "status")
 	status := GetRegexpSubmatch(inputData, "Controller Status *: (.*)")
+	if len(status) == 0 {
+		return Controller{}, NewParseError("controller status", v.execPath, args...)
+	}
 	model := GetRegexpSubmatch(inputData, "(.*) in Slot")
 	batteryStatus := GetRegexpSubmatch(inputData, "Battery/Capacitor Status *: (.*)")
 	cacheStatus := GetRegexpSubmatch(inputData, "Cache Status *: (.*)")
 
-	data := ReturnData{
+	data := Controller{
 		Status:        TrimSpacesLeftAndRight(status),
+		Health:        NewHealth(hpControllerStates.Get(status)),
 		Model:         TrimSpacesLeftAndRight(model),
 		BatteryStatus: TrimSpacesLeftAndRight(batteryStatus),
 		CacheStatus:   TrimSpacesLeftAndRight(cacheStatus),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
 // GetLDStatus - get logical drive status
-func (v HPVendor) GetLDStatus(controllerID string, deviceID string, indent int) []byte {
-	type ReturnData struct {
-		Status string `json:"status"`
-		Size   string `json:"size"`
+func (v HPVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	args := []string{"ctrl", fmt.Sprintf("slot=%s", controllerID), "ld", deviceID, "show", "detail"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return LogicalDrive{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "ctrl", fmt.Sprintf("slot=%s", controllerID), "ld", deviceID, "show", "detail")
 	status := GetRegexpSubmatch(inputData, "Status *: (.*)")
+	if len(status) == 0 {
+		return LogicalDrive{}, NewParseError("logical drive status", v.execPath, args...)
+	}
 	size := GetRegexpSubmatch(inputData, "Size *: (.*)")
 
-	data := ReturnData{
+	data := LogicalDrive{
 		Status: TrimSpacesLeftAndRight(status),
+		Health: NewHealth(hpLDStates.Get(status)),
 		Size:   TrimSpacesLeftAndRight(size),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
 // GetPDStatus - get physical drive status
-func (v HPVendor) GetPDStatus(controllerID string, deviceID string, indent int) []byte {
-	type ReturnData struct {
-		Status             string `json:"status"`
-		Model              string `json:"model"`
-		Size               string `json:"size"`
-		CurrentTemperature string `json:"currenttemperature"`
-		MaximumTemperature string `json:"maximumtemperature"`
+func (v HPVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	args := []string{"ctrl", fmt.Sprintf("slot=%s", controllerID), "pd", deviceID, "show", "detail"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return PhysicalDrive{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "ctrl", fmt.Sprintf("slot=%s", controllerID), "pd", deviceID, "show", "detail")
 	status := GetRegexpSubmatch(inputData, "[\\s]{2}Status: (.*)")
+	if len(status) == 0 {
+		return PhysicalDrive{}, NewParseError("physical drive status", v.execPath, args...)
+	}
 	model := GetRegexpSubmatch(inputData, "Model: (.*)")
 	size := GetRegexpSubmatch(inputData, "[\\s]{2}Size: (.*)")
 	currentTemperature := GetRegexpSubmatch(inputData, "Current Temperature \\(C\\): (.*)")
 	maximumTemperature := GetRegexpSubmatch(inputData, "Maximum Temperature \\(C\\): (.*)")
 
-	data := ReturnData{
+	data := PhysicalDrive{
 		Status:             TrimSpacesLeftAndRight(status),
+		Health:             NewHealth(hpPDStates.Get(status)),
 		Model:              TrimSpacesLeftAndRight(model),
 		Size:               TrimSpacesLeftAndRight(size),
 		CurrentTemperature: TrimSpacesLeftAndRight(currentTemperature),
 		MaximumTemperature: TrimSpacesLeftAndRight(maximumTemperature),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
+}
+
+// SmartDevices - drives behind Smart Array controller are addressed by their position in drive list
+// on SCSI generic device of controller
+func (v HPVendor) SmartDevices(controllerID string, host ControllerHost) (map[string]SmartDevice, error) {
+	ids, err := v.GetPhysicalDrivesIDs(controllerID)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := host.Host("hpsa", "smartpqi")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(h.Generic) == 0 {
+		return nil, fmt.Errorf("no SCSI generic device of host%d", h.Number)
+	}
+
+	data := map[string]SmartDevice{}
+
+	for n, id := range ids {
+		data[id] = SmartDevice{Type: fmt.Sprintf("cciss,%d", n), Path: h.Generic}
+	}
+
+	return data, nil
 }
 
-func NewHPVendor(execPath string) Vendor {
-	v := HPVendor{execPath: execPath}
+func NewHPVendor(execPath string, runner Runner) Vendor {
+	v := HPVendor{execPath: execPath, runner: runner}
 	return v
 }
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	logOutputStderr = "stderr"
+	logOutputSyslog = "syslog"
+	logSyslogTag    = "raidstat"
+	debugEnv        = "RAIDSTAT_DEBUG"
+)
+
+// LogLevel - logger verbosity, messages above logger level are dropped
+type LogLevel int
+
+const (
+	LogError LogLevel = iota
+	LogWarn
+	LogInfo
+	LogDebug
+	LogTrace
+)
+
+var logLevelNames = []string{"error", "warn", "info", "debug", "trace"}
+
+func (l LogLevel) String() string {
+	return logLevelNames[l]
+}
+
+// ParseLogLevel - level by name
+func ParseLogLevel(name string) (LogLevel, error) {
+	for i, n := range logLevelNames {
+		if n == name {
+			return LogLevel(i), nil
+		}
+	}
+
+	return LogError, fmt.Errorf("unknown log level '%s', must be one of '%s'", name, strings.Join(logLevelNames, " | "))
+}
+
+// LogConfig - log settings, 'Output' is stderr, syslog or absolute file path
+type LogConfig struct {
+	Level  string `json:"level"`
+	Output string `json:"output"`
+}
+
+// Validate - check log level and output
+func (c LogConfig) Validate() error {
+	if _, err := ParseLogLevel(c.Level); err != nil {
+		return err
+	}
+
+	if c.Output != logOutputStderr && c.Output != logOutputSyslog && !filepath.IsAbs(c.Output) {
+		return fmt.Errorf("output must be '%s', '%s' or absolute file path, got '%s'", logOutputStderr, logOutputSyslog, c.Output)
+	}
+
+	return nil
+}
+
+// Logger - leveled logger writing to stderr, file or syslog, never to stdout where json is printed
+type Logger struct {
+	Level  LogLevel
+	out    io.Writer
+	syslog *syslog.Writer
+}
+
+// logger - process wide logger, replaced by NewLogger result once options and config are read
+var logger = defaultLogger()
+
+// defaultLogger - warnings to stderr, RAIDSTAT_DEBUG=y enables trace
+func defaultLogger() *Logger {
+	l := &Logger{Level: LogWarn, out: os.Stderr}
+
+	if os.Getenv(debugEnv) == "y" {
+		l.Level = LogTrace
+	}
+
+	return l
+}
+
+// NewLogger - create logger for config
+func NewLogger(c LogConfig) (*Logger, error) {
+	level, err := ParseLogLevel(c.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Output {
+	case logOutputStderr:
+		return &Logger{Level: level, out: os.Stderr}, nil
+	case logOutputSyslog:
+		w, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, logSyslogTag)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to syslog: %w", err)
+		}
+		return &Logger{Level: level, syslog: w}, nil
+	}
+
+	f, err := os.OpenFile(c.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %w", err)
+	}
+
+	return &Logger{Level: level, out: f}, nil
+}
+
+// Enabled - whether messages of 'level' are written, lets callers skip expensive formatting
+func (l *Logger) Enabled(level LogLevel) bool {
+	return level <= l.Level
+}
+
+func (l *Logger) Errorf(format string, a ...interface{}) { l.logf(LogError, format, a...) }
+func (l *Logger) Warnf(format string, a ...interface{})  { l.logf(LogWarn, format, a...) }
+func (l *Logger) Infof(format string, a ...interface{})  { l.logf(LogInfo, format, a...) }
+func (l *Logger) Debugf(format string, a ...interface{}) { l.logf(LogDebug, format, a...) }
+func (l *Logger) Tracef(format string, a ...interface{}) { l.logf(LogTrace, format, a...) }
+
+// logf - format and write message of 'level'
+func (l *Logger) logf(level LogLevel, format string, a ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	msg := fmt.Sprintf(format, a...)
+
+	if l.syslog != nil {
+		switch level {
+		case LogError:
+			l.syslog.Err(msg)
+		case LogWarn:
+			l.syslog.Warning(msg)
+		case LogInfo:
+			l.syslog.Info(msg)
+		default:
+			l.syslog.Debug(msg)
+		}
+		return
+	}
+
+	fmt.Fprintf(l.out, "%s %s %s\n", time.Now().Format(time.RFC3339), strings.ToUpper(level.String()), msg)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoggerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	l := &Logger{Level: LogInfo, out: &buf}
+
+	l.Errorf("error %d", 1)
+	l.Warnf("warn %d", 2)
+	l.Infof("info %d", 3)
+	l.Debugf("debug %d", 4)
+	l.Tracef("trace %d", 5)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+
+	for i, want := range []string{"ERROR error 1", "WARN warn 2", "INFO info 3"} {
+		if !strings.HasSuffix(lines[i], want) {
+			t.Errorf("line %d is '%s', want suffix '%s'", i, lines[i], want)
+		}
+	}
+}
+
+func TestNewLoggerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "raidstat.log")
+
+	l, err := NewLogger(LogConfig{Level: "debug", Output: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l.Debugf("written")
+	l.Tracef("dropped")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), "DEBUG written") || strings.Contains(string(data), "dropped") {
+		t.Errorf("unexpected log file contents:\n%s", data)
+	}
+}
+
+func TestRegexpMatchLogging(t *testing.T) {
+	var buf bytes.Buffer
+
+	saved := logger
+	logger = &Logger{Level: LogDebug, out: &buf}
+	defer func() { logger = saved }()
+
+	input := []byte("Controller Status : Optimal\nTemperature : 40 C\n")
+
+	if s := GetRegexpSubmatch(input, "Temperature *: (.*) C"); s != "40" {
+		t.Errorf("got '%s', want '40'", s)
+	}
+
+	if s := GetRegexpSubmatch(input, "Battery *: (.*)"); s != "" {
+		t.Errorf("got '%s', want empty", s)
+	}
+
+	for _, want := range []string{
+		"regexp 'Temperature *: (.*) C' matched line 2: 'Temperature : 40 C'",
+		"regexp 'Battery *: (.*)' didn't match",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log doesn't contain '%s':\n%s", want, buf.String())
+		}
+	}
+}
@@ -1,30 +1,42 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ps78674/docopt.go"
 )
 
-const configFile = "config.json"
-
 var (
-	indent       int
-	toolVendor   string
-	toolBinary   string
-	operation    string
-	argOption    string
-	controllerID string
-	deviceID     string
+	indent        int
+	configPath    string
+	toolVendor    string
+	operation     string
+	argOption     string
+	controllerID  string
+	deviceID      string
+	errorOutput   string
+	logLevel      string
+	logOutput     string
+	recordDir     string
+	replayDir     string
+	anonymize     bool
+	listenAddr    string
+	scrapeTimeout int
+	textfilePath  string
+	senderServer  string
+	senderHost    string
 )
 
-var vendors = []string{"adaptec", "megacli", "hp", "marvell", "sas2ircu"}
+var vendors = []string{"adaptec", "megacli", "hp", "marvell", "sas2ircu", "sas3ircu", "storcli", "perccli", "mdraid", "zfs", "3ware", "areca", "nvme"}
+
+var errorOutputs = []string{"stderr", "stdout"}
 
-func init() {
+// parseArgs - parse and validate command line options
+func parseArgs() {
 	var (
 		discoveryOption string
 		statusOption    string
@@ -38,38 +50,115 @@ func init() {
 	var usage = fmt.Sprintf(`%[1]s: parse raid vendor tool output and format it as json
 
 Usage:
-  %[1]s (-v <VENDOR>) (-d <OPTION> | -s <OPTION>) [-i <INT>]
+  %[1]s [-v <VENDOR>] [-c <FILE>] (-d <OPTION> | -s <OPTION> | --dump) [-r <DIR>] [-i <INT>] [-e <OUTPUT>] [--log-level <LEVEL>] [--log-output <OUTPUT>]
+  %[1]s record -o <DIR> [-v <VENDOR>] [-c <FILE>] [--anonymize] [-i <INT>] [-e <OUTPUT>] [--log-level <LEVEL>] [--log-output <OUTPUT>]
+  %[1]s serve -l <ADDR> [-v <VENDOR>] [-c <FILE>] [-r <DIR>] [--scrape-timeout <SEC>] [-e <OUTPUT>] [--log-level <LEVEL>] [--log-output <OUTPUT>]
+  %[1]s textfile -o <FILE> [-v <VENDOR>] [-c <FILE>] [-r <DIR>] [-e <OUTPUT>] [--log-level <LEVEL>] [--log-output <OUTPUT>]
+  %[1]s send --server <ADDR> --host <NAME> [-v <VENDOR>] [-c <FILE>] [-r <DIR>] [-i <INT>] [-e <OUTPUT>] [--log-level <LEVEL>] [--log-output <OUTPUT>]
+
+Commands:
+  record                   run all discovery and status commands of vendors and save raw tool output
+                           with %[11]s to directory, bundle can be replayed or used as testdata
+  serve                    expose status of all controllers and drives as Prometheus metrics on %[12]s
+  textfile                 write the same metrics to file for node_exporter textfile collector, file is replaced atomically
+  send                     push discovery and status of all controllers and drives to Zabbix trapper items
 
 Options:
-  -v, --vendor <VENDOR>    raid tool vendor, one of: %[2]s
+  -v, --vendor <VENDOR>    raid tool vendor, one or comma separated list of: %[2]s
+                           or '%[7]s' to detect all present (default is 'default_vendor' from config),
+                           with several vendors controller ids are prefixed with vendor (ex.: megacli:0)
+  -c, --config <FILE>      config file, if not set first existing of:
+                           $%[5]s, %[6]s next to binary, /etc/raidstat/%[6]s
   -d, --discover <OPTION>  discovery option, one of: %[3]s
   -s, --status <OPTION>    status option, one of: %[4]s
+  --dump                   status of all controllers, logical and physical drives in one json
+  -o, --out <DIR>          directory tool output is recorded to, metrics file with 'textfile' (ex.: raid.prom)
+  --anonymize              replace serial numbers, WWNs and SAS addresses in recorded output with fake values
+  -r, --replay <DIR>       serve tool output recorded with 'record' from directory instead of running tools,
+                           '%[7]s' selects recorded vendors
+  -l, --listen <ADDR>      address metrics are served on (ex.: :9716)
+  --scrape-timeout <SEC>   seconds scrape waits for metrics collection [default: %[13]d]
+  --server <ADDR>          Zabbix server or proxy trapper address (port is %[14]s if not set)
+  --host <NAME>            host name items belong to in Zabbix
   -i, --indent <INT>       indent json output level [default: 0]
+  -e, --error-output <OUTPUT>
+                           where error json is written on failure, one of: %[8]s [default: stderr]
+  --log-level <LEVEL>      log level, one of: %[9]s (default is 'log.level' from config,
+                           $%[10]s=y sets trace)
+  --log-output <OUTPUT>    log output: stderr, syslog or absolute file path (default is 'log.output' from config)
 
   -h, --help               show this screen
-	`, programName, strings.Join(vendors, " | "), strings.Join(discoveryOptions, " | "), strings.Join(statusOptions, " | "))
+	`, programName, strings.Join(vendors, " | "), strings.Join(discoveryOptions, " | "), strings.Join(statusOptions, " | "), configEnv, configFile, autoVendor, strings.Join(errorOutputs, " | "), strings.Join(logLevelNames, " | "), debugEnv, manifestFile, metricsPath, defaultScrapeTimeout, defaultTrapperPort)
 
 	cmdOpts, err := docopt.ParseDoc(usage)
 	if err != nil {
-		fmt.Printf("error parsing options: %s\n", err)
-		os.Exit(1)
+		exitWithError(NewUsageError("error parsing options: %s", err), "")
 	}
 
 	toolVendor, _ = cmdOpts.String("--vendor")
+	configPath, _ = cmdOpts.String("--config")
 	discoveryOption, _ = cmdOpts.String("--discover")
 	statusOption, _ = cmdOpts.String("--status")
+	dumpOption, _ := cmdOpts.Bool("--dump")
 	indent, _ = cmdOpts.Int("--indent")
+	errorOutput, _ = cmdOpts.String("--error-output")
+	logLevel, _ = cmdOpts.String("--log-level")
+	logOutput, _ = cmdOpts.String("--log-output")
+	recordOption, _ := cmdOpts.Bool("record")
+	recordDir, _ = cmdOpts.String("--out")
+	anonymize, _ = cmdOpts.Bool("--anonymize")
+	replayDir, _ = cmdOpts.String("--replay")
+	serveOption, _ := cmdOpts.Bool("serve")
+	listenAddr, _ = cmdOpts.String("--listen")
+	scrapeTimeout, _ = cmdOpts.Int("--scrape-timeout")
+	textfileOption, _ := cmdOpts.Bool("textfile")
+	textfilePath, _ = cmdOpts.String("--out")
+	sendOption, _ := cmdOpts.Bool("send")
+	senderServer, _ = cmdOpts.String("--server")
+	senderHost, _ = cmdOpts.String("--host")
+
+	if err := validateVendors(toolVendor); err != nil && len(toolVendor) != 0 {
+		exitWithError(NewUsageError("vendors must be one or comma separated list of '%s' or '%s' (ex.: -v adaptec), got '%s'", strings.Join(vendors, " | "), autoVendor, toolVendor), toolVendor)
+	}
 
-	for i, v := range vendors {
-		if v != toolVendor {
-			if i == len(vendors)-1 {
-				fmt.Printf("Vendors must be one of '%s' (ex.: -v adaptec), got '%s'.\n", strings.Join(vendors, " | "), toolVendor)
-				docopt.PrintHelpOnly(nil, usage)
-				os.Exit(1)
-			}
-			continue
+	if errorOutput != "stderr" && errorOutput != "stdout" {
+		err := NewUsageError("error output must be one of '%s', got '%s'", strings.Join(errorOutputs, " | "), errorOutput)
+		errorOutput = "stderr"
+		exitWithError(err, toolVendor)
+	}
+
+	if recordOption {
+		operation = "Record"
+		argOption = "record"
+		return
+	}
+
+	if serveOption {
+		if scrapeTimeout <= 0 {
+			exitWithError(NewUsageError("scrape timeout must be positive, got %d", scrapeTimeout), toolVendor)
 		}
-		break
+
+		operation = "Serve"
+		argOption = "serve"
+		return
+	}
+
+	if textfileOption {
+		operation = "Textfile"
+		argOption = "textfile"
+		return
+	}
+
+	if sendOption {
+		operation = "Send"
+		argOption = "send"
+		return
+	}
+
+	if dumpOption {
+		operation = "Dump"
+		argOption = "all"
+		return
 	}
 
 	if len(discoveryOption) != 0 {
@@ -88,9 +177,7 @@ Options:
 
 		if argOptionValues[0] != rangeValues[0] || len(argOptionValues) != len(rangeValues) {
 			if i == len(options)-1 {
-				fmt.Printf("%s option must be one of '%s', got '%s'.\n", operation, strings.Join(options, " | "), argOption)
-				docopt.PrintHelpOnly(nil, usage)
-				os.Exit(1)
+				exitWithError(NewUsageError("%s option must be one of '%s', got '%s'", strings.ToLower(operation), strings.Join(options, " | "), argOption), toolVendor)
 			}
 
 			continue
@@ -113,7 +200,7 @@ Options:
 	}
 }
 
-func discoverControllers(v Vendor) {
+func discoverControllers(v Vendor) error {
 	type (
 		Element struct {
 			CT string `json:"{#CT_ID}"`
@@ -123,33 +210,21 @@ func discoverControllers(v Vendor) {
 		}
 	)
 
-	var (
-		d    []Element
-		JSON []byte
-		jErr error
-	)
+	var d []Element
 
-	controllersIDs := v.GetControllersIDs()
+	controllersIDs, err := v.GetControllersIDs()
+	if err != nil {
+		return err
+	}
 
 	for _, v := range controllersIDs {
 		d = append(d, Element{CT: v})
 	}
 
-	if indent > 0 {
-		JSON, jErr = json.MarshalIndent(Reply{d}, "", strings.Repeat(" ", indent))
-	} else {
-		JSON, jErr = json.Marshal(Reply{d})
-	}
-
-	if jErr != nil {
-		fmt.Println(jErr)
-		os.Exit(1)
-	}
-
-	os.Stdout.Write(append(JSON, "\n"...))
+	return printJSON(Reply{d})
 }
 
-func discoverLogicalDrives(v Vendor) {
+func discoverLogicalDrives(v Vendor) error {
 	type (
 		Element struct {
 			CT string `json:"{#CT_ID}"`
@@ -160,36 +235,29 @@ func discoverLogicalDrives(v Vendor) {
 		}
 	)
 
-	var (
-		d    []Element
-		JSON []byte
-		jErr error
-	)
+	var d []Element
+
+	controllersIDs, err := v.GetControllersIDs()
+	if err != nil {
+		return err
+	}
 
-	controllersIDs := v.GetControllersIDs()
 	for _, ctID := range controllersIDs {
-		logicalDrivesIDs := v.GetLogicalDrivesIDs(ctID)
+		logicalDrivesIDs, err := v.GetLogicalDrivesIDs(ctID)
+		if err != nil {
+			printError(err)
+			continue
+		}
+
 		for _, ldID := range logicalDrivesIDs {
 			d = append(d, Element{CT: ctID, LD: ldID})
 		}
-
-	}
-
-	if indent > 0 {
-		JSON, jErr = json.MarshalIndent(Reply{d}, "", strings.Repeat(" ", indent))
-	} else {
-		JSON, jErr = json.Marshal(Reply{d})
 	}
 
-	if jErr != nil {
-		fmt.Println(jErr)
-		os.Exit(1)
-	}
-
-	os.Stdout.Write(append(JSON, "\n"...))
+	return printJSON(Reply{d})
 }
 
-func discoverPhysicalDrives(v Vendor) {
+func discoverPhysicalDrives(v Vendor) error {
 	type (
 		Element struct {
 			CT string `json:"{#CT_ID}"`
@@ -200,95 +268,399 @@ func discoverPhysicalDrives(v Vendor) {
 		}
 	)
 
-	var (
-		d    []Element
-		JSON []byte
-		jErr error
-	)
+	var d []Element
+
+	controllersIDs, err := v.GetControllersIDs()
+	if err != nil {
+		return err
+	}
 
-	controllersIDs := v.GetControllersIDs()
 	for _, ctID := range controllersIDs {
-		logicalDrivesIDs := v.GetPhysicalDrivesIDs(ctID)
-		for _, pdID := range logicalDrivesIDs {
+		physicalDrivesIDs, err := v.GetPhysicalDrivesIDs(ctID)
+		if err != nil {
+			printError(err)
+			continue
+		}
+
+		for _, pdID := range physicalDrivesIDs {
 			d = append(d, Element{CT: ctID, PD: pdID})
 		}
+	}
+
+	return printJSON(Reply{d})
+}
+
+func dumpAll(v Vendor) error {
+	data, err := CollectDump(v)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(data)
+}
+
+func getControllerStatus(v Vendor, controllerID string) error {
+	data, err := v.GetControllerStatus(controllerID)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(data)
+}
+
+func getLDStatus(v Vendor, controllerID string, deviceID string) error {
+	data, err := v.GetLDStatus(controllerID, deviceID)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(data)
+}
+
+func getPDStatus(v Vendor, controllerID string, deviceID string) error {
+	data, err := v.GetPDStatus(controllerID, deviceID)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(data)
+}
+
+// recordVendors - run all discovery and status commands of vendors and save their output to 'recordDir'
+func recordVendors(names []string, c Config) error {
+	r := &Recorder{Dir: recordDir, Anonymize: anonymize}
+
+	for _, name := range names {
+		binary := c.VendorExecPath(name)
+
+		v, err := NewVendorWithRunner(name, c, r.Runner(name, binary, newRunner(name, c)), r.Runner(name, binary, newSmartRunner(c)))
+		if err != nil {
+			return err
+		}
 
+		// failed commands are recorded as well, dump is only used to run all of them
+		if _, err := CollectDump(v); err != nil {
+			printError(fmt.Errorf("%s: %w", name, err))
+		}
 	}
 
-	if indent > 0 {
-		JSON, jErr = json.MarshalIndent(Reply{d}, "", strings.Repeat(" ", indent))
-	} else {
-		JSON, jErr = json.Marshal(Reply{d})
+	m, err := r.Write()
+	if err != nil {
+		return fmt.Errorf("error writing record: %w", err)
+	}
+
+	return printJSON(m)
+}
+
+// selectVendors - resolve comma separated vendor list, 'auto' is replaced with detected vendors
+func selectVendors(list string, c Config) ([]string, error) {
+	var (
+		data []string
+		seen = map[string]bool{}
+	)
+
+	for _, name := range strings.Split(list, ",") {
+		selected := []string{name}
+
+		if name == autoVendor && len(replayDir) != 0 {
+			recorded, err := replayVendors(replayDir)
+			if err != nil {
+				return nil, err
+			}
+
+			selected = recorded
+		} else if name == autoVendor {
+			detected, err := NewDetector(c.SysfsRoot).Detect(c)
+			if err != nil {
+				return nil, fmt.Errorf("error detecting vendor: %w", err)
+			}
+
+			if len(detected) == 0 {
+				return nil, fmt.Errorf("no supported RAID controllers detected")
+			}
+
+			selected = detected
+		}
+
+		for _, v := range selected {
+			if !seen[v] {
+				seen[v] = true
+				data = append(data, v)
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// replayVendors - vendors recorded in 'dir', in 'vendors' order
+func replayVendors(dir string) ([]string, error) {
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []string
+	for _, v := range vendors {
+		if _, ok := m.Vendors[v]; ok {
+			data = append(data, v)
+		}
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no supported vendors recorded in '%s'", dir)
+	}
+
+	return data, nil
+}
+
+// newVendor - create vendor running its tool or replaying recorded output with --replay
+func newVendor(name string, c Config) (Vendor, error) {
+	if len(replayDir) == 0 {
+		return NewVendor(name, c)
+	}
+
+	r, err := NewReplayRunner(replayDir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewVendorWithRunner(name, c, r, r)
+}
+
+// newVendors - create single vendor or MultiVendor with namespaced controller ids for several
+func newVendors(names []string, c Config) (Vendor, error) {
+	if len(names) == 1 {
+		return newVendor(names[0], c)
+	}
+
+	vendors := map[string]Vendor{}
+	for _, name := range names {
+		v, err := newVendor(name, c)
+		if err != nil {
+			return nil, err
+		}
+
+		vendors[name] = v
+	}
+
+	return NewMultiVendor(names, vendors, printError), nil
+}
+
+// newVendorsMap - vendors by name, metrics are labelled with vendor instead of namespacing controller ids
+func newVendorsMap(names []string, c Config) (map[string]Vendor, error) {
+	data := map[string]Vendor{}
+
+	for _, name := range names {
+		v, err := newVendor(name, c)
+		if err != nil {
+			return nil, err
+		}
+
+		data[name] = v
 	}
 
-	if jErr != nil {
-		fmt.Println(jErr)
-		os.Exit(1)
+	return data, nil
+}
+
+// newExporter - exporter of vendors with scrape timeout from options
+func newExporter(names []string, c Config) (*Exporter, error) {
+	vendors, err := newVendorsMap(names, c)
+	if err != nil {
+		return nil, err
 	}
 
-	os.Stdout.Write(append(JSON, "\n"...))
+	return &Exporter{Names: names, Vendors: vendors, Timeout: time.Duration(scrapeTimeout) * time.Second}, nil
 }
 
-func getControllerStatus(v Vendor, controllerID string) {
-	os.Stdout.Write(v.GetControllerStatus(controllerID, indent))
+// sendVendors - collect items of vendors once and push them to trapper, keys have 'vendor' parameter like {$RAID_VENDOR}
+func sendVendors(names []string, vendor string, c Config) error {
+	v, err := newVendors(names, c)
+	if err != nil {
+		return err
+	}
+
+	items, err := CollectSenderItems(senderHost, vendor, v)
+	if err != nil {
+		return err
+	}
+
+	r, err := SendItems(senderServer, items, senderTimeout)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(r)
+}
+
+// printJSON - marshal data and write it to stdout
+func printJSON(data interface{}) error {
+	JSON, err := MarshallJSON(data, indent)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(JSON)
+	return err
 }
 
-func getLDStatus(v Vendor, controllerID string, deviceID string) {
-	os.Stdout.Write(v.GetLDStatus(controllerID, deviceID, indent))
+// printError - report error without interrupting execution
+func printError(err error) {
+	logger.Errorf("%s", err)
 }
 
-func getPDStatus(v Vendor, controllerID string, deviceID string) {
-	os.Stdout.Write(v.GetPDStatus(controllerID, deviceID, indent))
+// setupLogger - replace default logger with one configured by options, config and RAIDSTAT_DEBUG
+func setupLogger(c LogConfig) error {
+	if os.Getenv(debugEnv) == "y" {
+		c.Level = LogTrace.String()
+	}
+
+	if len(logLevel) != 0 {
+		c.Level = logLevel
+	}
+
+	if len(logOutput) != 0 {
+		c.Output = logOutput
+	}
+
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	l, err := NewLogger(c)
+	if err != nil {
+		return err
+	}
+
+	logger = l
+	return nil
 }
 
-type Vendor interface {
-	GetControllersIDs() []string
-	GetLogicalDrivesIDs(string) []string
-	GetPhysicalDrivesIDs(string) []string
-	GetControllerStatus(string, int) []byte
-	GetLDStatus(string, string, int) []byte
-	GetPDStatus(string, string, int) []byte
+// exitWithError - write error json to selected output and exit with code of error
+func exitWithError(err error, vendor string) {
+	report := NewErrorReport(err, vendor)
+
+	out := os.Stderr
+	if errorOutput == "stdout" {
+		out = os.Stdout
+	}
+
+	if JSON, jErr := MarshallJSON(report, indent); jErr == nil {
+		out.Write(JSON)
+	} else {
+		printError(err)
+	}
+
+	os.Exit(report.ExitCode())
 }
 
 func main() {
-	var v Vendor
-	switch toolVendor {
-	case "adaptec":
-		v = NewAdaptecVendor("arcconf")
-	case "megacli":
-		v = NewMegacliVendor("megacli")
-	case "hp":
-		v = NewHPVendor("ssacli")
-	case "marvell":
-		v = NewMarvellVendor("mvcli")
-	case "sas2ircu":
-		v = NewSAS2IrcuVendor("sas2ircu")
-	default:
-		fmt.Printf("unknown vendor %q", toolVendor)
-		os.Exit(1)
+	parseArgs()
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		exitWithError(err, toolVendor)
+	}
+
+	if err := setupLogger(config.Log); err != nil {
+		exitWithError(fmt.Errorf("error setting up log: %w", err), toolVendor)
+	}
+
+	if len(toolVendor) == 0 {
+		toolVendor = config.DefaultVendor
+	}
+
+	if len(toolVendor) == 0 {
+		exitWithError(fmt.Errorf("vendor is not set, use -v option or 'default_vendor' in config"), toolVendor)
+	}
+
+	if err := validateVendors(toolVendor); err != nil {
+		exitWithError(err, toolVendor)
+	}
+
+	// namespaced controller id selects its vendor, no detection needed
+	if name, ctID, ok := SplitControllerID(controllerID); ok && operation == "Status" {
+		toolVendor = name
+		controllerID = ctID
+	}
+
+	names, err := selectVendors(toolVendor, config)
+	if err != nil {
+		exitWithError(err, toolVendor)
+	}
+
+	logger.Infof("using vendors: %s", strings.Join(names, ", "))
+
+	if operation == "Record" {
+		if err := recordVendors(names, config); err != nil {
+			exitWithError(err, strings.Join(names, ","))
+		}
+		return
+	}
+
+	if operation == "Serve" {
+		e, err := newExporter(names, config)
+		if err != nil {
+			exitWithError(err, strings.Join(names, ","))
+		}
+
+		if err := serveMetrics(listenAddr, e); err != nil {
+			exitWithError(err, strings.Join(names, ","))
+		}
+		return
+	}
+
+	if operation == "Send" {
+		if err := sendVendors(names, toolVendor, config); err != nil {
+			exitWithError(err, strings.Join(names, ","))
+		}
+		return
+	}
+
+	if operation == "Textfile" {
+		vendors, err := newVendorsMap(names, config)
+		if err != nil {
+			exitWithError(err, strings.Join(names, ","))
+		}
+
+		if err := WriteTextfile(textfilePath, names, vendors, time.Now()); err != nil {
+			exitWithError(err, strings.Join(names, ","))
+		}
+		return
+	}
+
+	v, err := newVendors(names, config)
+	if err != nil {
+		exitWithError(err, strings.Join(names, ","))
 	}
 
 	switch argOption {
+	case "all":
+		err = dumpAll(v)
 	case "ct":
 		switch operation {
 		case "Discovery":
-			discoverControllers(v)
+			err = discoverControllers(v)
 		case "Status":
-			getControllerStatus(v, controllerID)
+			err = getControllerStatus(v, controllerID)
 		}
 	case "ld":
 		switch operation {
 		case "Discovery":
-			discoverLogicalDrives(v)
+			err = discoverLogicalDrives(v)
 		case "Status":
-			getLDStatus(v, controllerID, deviceID)
+			err = getLDStatus(v, controllerID, deviceID)
 		}
 	case "pd":
 		switch operation {
 		case "Discovery":
-			discoverPhysicalDrives(v)
+			err = discoverPhysicalDrives(v)
 		case "Status":
-			getPDStatus(v, controllerID, deviceID)
+			err = getPDStatus(v, controllerID, deviceID)
 		}
 	}
+
+	if err != nil {
+		exitWithError(err, strings.Join(names, ","))
+	}
 }
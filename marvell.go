@@ -5,39 +5,77 @@ import (
 	"strings"
 )
 
+// mvcli status values to normalized state
+var (
+	marvellLDStates = StateMap{
+		"optimal":    StateOK,
+		"background": StateWarning,
+		"rebuilding": StateWarning,
+		"degraded":   StateCritical,
+		"offline":    StateCritical,
+	}
+	marvellPDStates = StateMap{
+		"online":  StateOK,
+		"missing": StateCritical,
+		"offline": StateCritical,
+	}
+)
+
 type MarvellVendor struct {
 	execPath string
+	runner   Runner
 }
 
 // GetControllersIDs - get number of controllers in the system
-func (v MarvellVendor) GetControllersIDs() []string {
-	inputData := GetCommandOutput(v.execPath, "info", "-o", "hba")
-	return GetRegexpAllSubmatch(inputData, "Adapter ID:[\\s]+(.*)")
+func (v MarvellVendor) GetControllersIDs() ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "info", "-o", "hba")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "Adapter ID:[\\s]+(.*)"), nil
 }
 
 // GetLogicalDrivesIDs - get number of logical drives for controller with ID 'controllerID'
-func (v MarvellVendor) GetLogicalDrivesIDs(controllerID string) []string {
-	GetCommandOutput(v.execPath, "adapter", "-i", controllerID)
-	inputData := GetCommandOutput(v.execPath, "info", "-o", "ld")
-	return GetRegexpAllSubmatch(inputData, "id:[\\s]+(.*)")
+func (v MarvellVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	if _, err := v.runner.Run(v.execPath, "adapter", "-i", controllerID); err != nil {
+		return nil, err
+	}
+
+	inputData, err := v.runner.Run(v.execPath, "info", "-o", "ld")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "id:[\\s]+(.*)"), nil
 }
 
 // GetPhysicalDrivesIDs - get number of physical drives for controller with ID 'controllerID'
-func (v MarvellVendor) GetPhysicalDrivesIDs(controllerID string) []string {
-	GetCommandOutput(v.execPath, "adapter", "-i", controllerID)
-	inputData := GetCommandOutput(v.execPath, "info", "-o", "pd")
-	return GetRegexpAllSubmatch(inputData, "PD ID:[\\s]+(.*)")
+func (v MarvellVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	if _, err := v.runner.Run(v.execPath, "adapter", "-i", controllerID); err != nil {
+		return nil, err
+	}
+
+	inputData, err := v.runner.Run(v.execPath, "info", "-o", "pd")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "PD ID:[\\s]+(.*)"), nil
 }
 
 // GetControllerStatus - get controller status
-func (v MarvellVendor) GetControllerStatus(controllerID string, indent int) []byte {
-	type ReturnData struct {
-		Status      string `json:"status"`
-		ModelNumber string `json:"modelnumber"`
-		PartNumber  string `json:"partnumber"`
+func (v MarvellVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	args := []string{"info", "-o", "hba", "-i", controllerID}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return Controller{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "info", "-o", "hba", "-i", controllerID)
+	if len(GetRegexpSubmatch(inputData, "Image health:[\\s]+(.*)")) == 0 {
+		return Controller{}, NewParseError("controller image health", v.execPath, args...)
+	}
 
 	healthStatuses := []string{}
 	for _, v := range []string{
@@ -53,88 +91,109 @@ func (v MarvellVendor) GetControllerStatus(controllerID string, indent int) []by
 		}
 	}
 
-	var status string
+	var (
+		status string
+		state  = StateOK
+	)
+
 	if len(healthStatuses) == 0 {
 		status = "OK"
 	} else {
+		// damaged flash image doesn't affect running controller
 		status = strings.Join(healthStatuses, ", ")
+		state = StateWarning
 	}
 
 	modelnumber := GetRegexpSubmatch(inputData, "ModelNumber:[\\s]+(.*)")
 	partnumber := GetRegexpSubmatch(inputData, "PartNumber:[\\s]+(.*)")
 
-	data := ReturnData{
+	data := Controller{
 		Status:      TrimSpacesLeftAndRight(status),
+		Health:      NewHealth(state),
 		ModelNumber: TrimSpacesLeftAndRight(modelnumber),
 		PartNumber:  TrimSpacesLeftAndRight(partnumber),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
 // GetLDStatus - get logical drive status
-func (v MarvellVendor) GetLDStatus(controllerID string, deviceID string, indent int) []byte {
-	type ReturnData struct {
-		Status   string `json:"status"`
-		Name     string `json:"name"`
-		Size     string `json:"size"`
-		RaidMode string `json:"raidmode"`
+func (v MarvellVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	// set adapter for next commands (mvcli-specific)
+	if _, err := v.runner.Run(v.execPath, "adapter", "-i", controllerID); err != nil {
+		return LogicalDrive{}, err
+	}
+
+	args := []string{"info", "-o", "ld", "-i", deviceID}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return LogicalDrive{}, err
 	}
 
-	GetCommandOutput(v.execPath, "adapter", "-i", controllerID) // set adapter for next commands (mvcli-specific)
-	inputData := GetCommandOutput(v.execPath, "info", "-o", "ld", "-i", deviceID)
 	status := GetRegexpSubmatch(inputData, "VD status:[\\s]+(.*)")
-	name := GetRegexpSubmatch(inputData, "name:[\\s]+(.*)")
-	size := GetRegexpSubmatch(inputData, "size:[\\s]+(.*)")
+	if len(status) == 0 {
+		return LogicalDrive{}, NewParseError("logical drive status", v.execPath, args...)
+	}
+	// anchored to skip 'Stripe size'
+	name := GetRegexpSubmatch(inputData, "(?m)^name:[\\s]+(.*)")
+	size := GetRegexpSubmatch(inputData, "(?m)^size:[\\s]+(.*)")
 	raidmode := GetRegexpSubmatch(inputData, "RAID mode:[\\s]+(.*)")
+	health := NewHealth(marvellLDStates.Get(status))
 
 	if status == "optimal" {
 		status = "OK"
 	}
 
-	data := ReturnData{
+	data := LogicalDrive{
 		Status:   TrimSpacesLeftAndRight(status),
+		Health:   health,
 		Name:     TrimSpacesLeftAndRight(name),
 		Size:     TrimSpacesLeftAndRight(size),
 		RaidMode: TrimSpacesLeftAndRight(raidmode),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
 // GetPDStatus - get physical drive status
-func (v MarvellVendor) GetPDStatus(controllerID string, deviceID string, indent int) []byte {
-	type ReturnData struct {
-		Status          string `json:"status"`
-		Model           string `json:"model"`
-		FirmwareVersion string `json:"firmwareversion"`
-		Size            string `json:"size"`
-		CurrentSpeed    string `json:"currentspeed"`
+func (v MarvellVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	args := []string{"info", "-o", "pd", "-i", deviceID}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return PhysicalDrive{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "info", "-o", "pd", "-i", deviceID)
 	status := GetRegexpSubmatch(inputData, "PD status:[\\s]+(.*)")
+	if len(status) == 0 {
+		return PhysicalDrive{}, NewParseError("physical drive status", v.execPath, args...)
+	}
 	model := GetRegexpSubmatch(inputData, "model:[\\s]+(.*)")
 	firmwareversion := GetRegexpSubmatch(inputData, "Firmware version:[\\s]+(.*)")
 	size := GetRegexpSubmatch(inputData, "Size:[\\s]+(.*)")
 	currentspeed := GetRegexpSubmatch(inputData, "Current speed:[\\s]+(.*)")
+	health := NewHealth(marvellPDStates.Get(status))
 
 	if status == "online" {
 		status = "OK"
 	}
 
-	data := ReturnData{
+	data := PhysicalDrive{
 		Status:          TrimSpacesLeftAndRight(status),
+		Health:          health,
 		Model:           TrimSpacesLeftAndRight(model),
 		FirmwareVersion: TrimSpacesLeftAndRight(firmwareversion),
 		Size:            TrimSpacesLeftAndRight(size),
 		CurrentSpeed:    TrimSpacesLeftAndRight(currentspeed),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
-func NewMarvellVendor(execPath string) Vendor {
-	v := MarvellVendor{execPath: execPath}
+// NewMarvellVendor - Marvell RAID controllers managed by mvcli, it has no SmartMapper: RAID members are hidden
+// behind the virtual disk and smartctl has no device type passing commands through the controller
+func NewMarvellVendor(execPath string, runner Runner) Vendor {
+	v := MarvellVendor{execPath: execPath, runner: runner}
 	return v
 }
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	mdstatPath      = "proc/mdstat"
+	mdraidSysfsPath = "sys/block"
+	// all md arrays are reported as logical drives of single controller
+	mdraidControllerID = "0"
+)
+
+// md array and member states to normalized state
+var (
+	mdraidLDStates = StateMap{
+		"clean":      StateOK,
+		"checking":   StateOK,
+		"repairing":  StateOK,
+		"resyncing":  StateWarning,
+		"recovering": StateWarning,
+		"reshaping":  StateWarning,
+		"degraded":   StateCritical,
+		"inactive":   StateCritical,
+		"broken":     StateCritical,
+	}
+	// member state is comma separated list of flags, flags not in table ('write_mostly') don't change state
+	mdraidPDStates = StateMap{
+		"in_sync":          StateOK,
+		"spare":            StateOK,
+		"rebuilding":       StateWarning,
+		"write_error":      StateWarning,
+		"want_replacement": StateWarning,
+		"replacement":      StateWarning,
+		"faulty":           StateCritical,
+		"blocked":          StateCritical,
+	}
+	// status of array running sync_action, 'idle' and 'frozen' don't change status
+	mdraidSyncStatuses = map[string]string{
+		"resync":  "resyncing",
+		"recover": "recovering",
+		"reshape": "reshaping",
+		"check":   "checking",
+		"repair":  "repairing",
+	}
+)
+
+var mdstatMember = regexp.MustCompile(`^(\S+)\[(\d+)\]((?:\(\w\))*)$`)
+
+// mdArray - array as listed in /proc/mdstat
+type mdArray struct {
+	name     string
+	active   bool
+	level    string
+	blocks   int64
+	members  []mdMember
+	progress string
+}
+
+// mdMember - array member, 'flags' are mdstat flags: 'F' faulty, 'S' spare, 'W' write mostly, 'R' replacement
+type mdMember struct {
+	name  string
+	role  int
+	flags string
+}
+
+// count - number of members with flag
+func (a mdArray) count(flag string) int {
+	var n int
+	for _, m := range a.members {
+		if strings.Contains(m.flags, flag) {
+			n++
+		}
+	}
+
+	return n
+}
+
+type MdraidVendor struct {
+	root   string
+	runner Runner
+}
+
+// read - contents of file under root
+func (v MdraidVendor) read(path ...string) (string, error) {
+	data, err := v.runner.Run(v.root, strings.Join(path, "/"))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// arrays - arrays listed in /proc/mdstat, missing mdstat means md driver is not loaded and there are no arrays
+func (v MdraidVendor) arrays() ([]mdArray, error) {
+	inputData, err := v.runner.Run(v.root, mdstatPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var data []mdArray
+
+	for _, r := range FindRegexpSubmatches(inputData, "(?m)^(md\\S+) : (\\S+)(.*)\\n((?:[ \\t]+.*\\n?)*)", -1) {
+		a := mdArray{name: r[1], active: r[2] == "active"}
+
+		for _, f := range strings.Fields(r[3]) {
+			if m := mdstatMember.FindStringSubmatch(f); m != nil {
+				role, _ := strconv.Atoi(m[2])
+				a.members = append(a.members, mdMember{name: m[1], role: role, flags: strings.NewReplacer("(", "", ")", "").Replace(m[3])})
+			} else if !strings.HasPrefix(f, "(") && len(a.members) == 0 {
+				a.level = f
+			}
+		}
+
+		sort.SliceStable(a.members, func(i, j int) bool { return a.members[i].role < a.members[j].role })
+
+		a.blocks, _ = strconv.ParseInt(GetRegexpSubmatch([]byte(r[4]), "(\\d+) blocks"), 10, 64)
+		a.progress = GetRegexpSubmatch([]byte(r[4]), "(?:resync|recovery|reshape|check|repair)\\s*=\\s*([\\d.]+)%")
+
+		data = append(data, a)
+	}
+
+	return data, nil
+}
+
+// array - array 'name' from /proc/mdstat
+func (v MdraidVendor) array(controllerID string, name string) (mdArray, error) {
+	if controllerID != mdraidControllerID {
+		return mdArray{}, NewUnknownDeviceError("unknown controller '%s'", controllerID)
+	}
+
+	arrays, err := v.arrays()
+	if err != nil {
+		return mdArray{}, err
+	}
+
+	for _, a := range arrays {
+		if a.name == name {
+			return a, nil
+		}
+	}
+
+	return mdArray{}, NewUnknownDeviceError("md array '%s' not found in %s", name, mdstatPath)
+}
+
+// GetControllersIDs - single controller when there are md arrays
+func (v MdraidVendor) GetControllersIDs() ([]string, error) {
+	arrays, err := v.arrays()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(arrays) == 0 {
+		return []string{}, nil
+	}
+
+	return []string{mdraidControllerID}, nil
+}
+
+// GetLogicalDrivesIDs - get md array names
+func (v MdraidVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	arrays, err := v.arrays()
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, a := range arrays {
+		data = append(data, a.name)
+	}
+
+	return data, nil
+}
+
+// GetPhysicalDrivesIDs - get member devices of all md arrays
+func (v MdraidVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	arrays, err := v.arrays()
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, a := range arrays {
+		for _, m := range a.members {
+			data = append(data, m.name)
+		}
+	}
+
+	return data, nil
+}
+
+// GetControllerStatus - worst state of all md arrays
+func (v MdraidVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	if controllerID != mdraidControllerID {
+		return Controller{}, NewUnknownDeviceError("unknown controller '%s'", controllerID)
+	}
+
+	arrays, err := v.arrays()
+	if err != nil {
+		return Controller{}, err
+	}
+
+	healthStatuses := []string{}
+	state := StateOK
+
+	for _, a := range arrays {
+		ld, err := v.arrayStatus(a)
+		if err != nil {
+			return Controller{}, err
+		}
+
+		if ld.StateCode != StateOK {
+			healthStatuses = append(healthStatuses, fmt.Sprintf("%s is %s", a.name, ld.Status))
+		}
+
+		state = state.Worse(ld.StateCode)
+	}
+
+	status := "OK"
+	if len(healthStatuses) > 0 {
+		status = strings.Join(healthStatuses, "; ")
+	}
+
+	data := Controller{
+		Status: status,
+		Health: NewHealth(state),
+		Model:  "Linux software RAID",
+	}
+
+	return data, nil
+}
+
+// GetLDStatus - get md array status, 'deviceID' is array name ('md0')
+func (v MdraidVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	a, err := v.array(controllerID, deviceID)
+	if err != nil {
+		return LogicalDrive{}, err
+	}
+
+	return v.arrayStatus(a)
+}
+
+// arrayStatus - status of array from mdstat and its sysfs attributes
+func (v MdraidVendor) arrayStatus(a mdArray) (LogicalDrive, error) {
+	data := LogicalDrive{
+		Status:       "inactive",
+		Name:         a.name,
+		RaidMode:     a.level,
+		FailedDrives: strconv.Itoa(a.count("F")),
+		SpareDrives:  strconv.Itoa(a.count("S")),
+	}
+
+	if a.blocks > 0 {
+		data.Size = fmt.Sprintf("%d MB", a.blocks/1024)
+	}
+
+	if a.active {
+		md := []string{mdraidSysfsPath, a.name, "md"}
+
+		arrayState, err := v.read(append(md, "array_state")...)
+		if err != nil {
+			return LogicalDrive{}, err
+		}
+
+		// degraded and sync_action exist for redundant levels only
+		degraded, _ := v.read(append(md, "degraded")...)
+		action, _ := v.read(append(md, "sync_action")...)
+
+		switch {
+		case arrayState == "inactive" || arrayState == "broken":
+			data.Status = arrayState
+		case action == "recover":
+			data.Status = mdraidSyncStatuses[action]
+		case len(degraded) != 0 && degraded != "0":
+			data.Status = "degraded"
+		case len(mdraidSyncStatuses[action]) != 0:
+			data.Status = mdraidSyncStatuses[action]
+		default:
+			data.Status = "clean"
+		}
+
+		if len(mdraidSyncStatuses[action]) != 0 {
+			data.Progress = a.progress
+		}
+
+		if level, err := v.read(append(md, "level")...); err == nil && len(level) != 0 {
+			data.RaidMode = level
+		}
+	}
+
+	data.Health = NewHealth(mdraidLDStates.Get(data.Status))
+
+	return data, nil
+}
+
+// GetPDStatus - get md array member status, 'deviceID' is member block device name ('sda1')
+func (v MdraidVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	if controllerID != mdraidControllerID {
+		return PhysicalDrive{}, NewUnknownDeviceError("unknown controller '%s'", controllerID)
+	}
+
+	arrays, err := v.arrays()
+	if err != nil {
+		return PhysicalDrive{}, err
+	}
+
+	for _, a := range arrays {
+		for _, m := range a.members {
+			if m.name == deviceID {
+				return v.memberStatus(a, m)
+			}
+		}
+	}
+
+	return PhysicalDrive{}, NewUnknownDeviceError("md array member '%s' not found in %s", deviceID, mdstatPath)
+}
+
+// memberStatus - status of array member from its sysfs state
+func (v MdraidVendor) memberStatus(a mdArray, m mdMember) (PhysicalDrive, error) {
+	dev := "dev-" + m.name
+
+	memberState, err := v.read(mdraidSysfsPath, a.name, "md", dev, "state")
+	if err != nil {
+		return PhysicalDrive{}, err
+	}
+
+	flags := strings.Split(memberState, ",")
+
+	// spare with role inside array is being rebuilt, real spares are marked in mdstat
+	for i, f := range flags {
+		if f == "spare" && !strings.Contains(m.flags, "S") {
+			flags[i] = "rebuilding"
+		}
+	}
+
+	// worst of known flags, unknown when no flag is known
+	state := StateUnknown
+	for _, f := range flags {
+		if s := mdraidPDStates.Get(f); s == StateUnknown {
+			continue
+		} else if state == StateUnknown {
+			state = s
+		} else {
+			state = state.Worse(s)
+		}
+	}
+
+	status := strings.Join(flags, ",")
+	if status == "in_sync" {
+		status = "OK"
+	}
+
+	data := PhysicalDrive{
+		Status: status,
+		Health: NewHealth(state),
+	}
+
+	if size, err := v.read(mdraidSysfsPath, a.name, "md", dev, "size"); err == nil {
+		if kb, err := strconv.ParseInt(size, 10, 64); err == nil {
+			data.Size = fmt.Sprintf("%d MB", kb/1024)
+		}
+	}
+
+	return data, nil
+}
+
+// SmartDevices - array member is partition or whole disk with its own block device
+func (v MdraidVendor) SmartDevices(controllerID string, host ControllerHost) (map[string]SmartDevice, error) {
+	ids, err := v.GetPhysicalDrivesIDs(controllerID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]SmartDevice{}
+
+	for _, id := range ids {
+		data[id] = blockSmartDevice(id)
+	}
+
+	return data, nil
+}
+
+// NewMdraidVendor - Linux software RAID, '/proc' and '/sys' are read under 'root'
+func NewMdraidVendor(root string, runner Runner) Vendor {
+	v := MdraidVendor{root: root, runner: runner}
+	return v
+}
@@ -2,38 +2,72 @@ package main
 
 import (
 	"fmt"
-	"os"
-	"regexp"
 	"strings"
 )
 
+// megacli status values to normalized state
+var (
+	// device counters in adapter info, non-zero value sets controller state
+	megacliControllerCounters = map[string]State{
+		"Degraded":       StateCritical,
+		"Offline":        StateCritical,
+		"Critical Disks": StateWarning,
+		"Failed Disks":   StateCritical,
+	}
+	megacliLDStates = StateMap{
+		"Optimal":            StateOK,
+		"Partially Degraded": StateWarning,
+		"Degraded":           StateCritical,
+		"Offline":            StateCritical,
+	}
+	megacliPDStates = StateMap{
+		"Online":             StateOK,
+		"Hotspare":           StateOK,
+		"Unconfigured(good)": StateOK,
+		"JBOD":               StateOK,
+		"Rebuild":            StateWarning,
+		"Copyback":           StateWarning,
+		"Failed":             StateCritical,
+		"Offline":            StateCritical,
+		"Missing":            StateCritical,
+		"Unconfigured(bad)":  StateCritical,
+	}
+)
+
 type MegacliVendor struct {
 	execPath string
+	runner   Runner
 }
 
 // GetControllersIDs - get number of controllers in the system
-func (v MegacliVendor) GetControllersIDs() []string {
-	inputData := GetCommandOutput(v.execPath, "-AdpGetPciInfo", "-aALL")
-	return GetRegexpAllSubmatch(inputData, "for Controller (\\d*)")
+func (v MegacliVendor) GetControllersIDs() ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "-AdpGetPciInfo", "-aALL")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "for Controller (\\d*)"), nil
 }
 
 // GetLogicalDrivesIDs - get number of logical drives for controller with ID 'controllerID'
-func (v MegacliVendor) GetLogicalDrivesIDs(controllerID string) []string {
-	inputData := GetCommandOutput(v.execPath, "-LdInfo", "-Lall", fmt.Sprintf("-a%s", controllerID), "-NoLog")
-	return GetRegexpAllSubmatch(inputData, "Virtual Drive: (.*?)[\\s]")
+func (v MegacliVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "-LdInfo", "-Lall", fmt.Sprintf("-a%s", controllerID), "-NoLog")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "Virtual Drive: (.*?)[\\s]"), nil
 }
 
 // GetPhysicalDrivesIDs - get number of physical drives for controller with ID 'controllerID'
-func (v MegacliVendor) GetPhysicalDrivesIDs(controllerID string) []string {
-	inputData := GetCommandOutput(v.execPath, "-PDList", fmt.Sprintf("-a%s", controllerID), "-NoLog")
-
-	result := regexp.MustCompile("Enclosure Device ID: (\\d+)\\nSlot Number: (\\d+)").FindAllStringSubmatch(string(inputData), -1)
-
-	if os.Getenv("RAIDSTAT_DEBUG") == "y" {
-		fmt.Printf("Regexp is '%s'\n", "Enclosure Device ID: (\\d+)\\nSlot Number: (\\d+)")
-		fmt.Printf("Result is '%s'\n", result)
+func (v MegacliVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "-PDList", fmt.Sprintf("-a%s", controllerID), "-NoLog")
+	if err != nil {
+		return nil, err
 	}
 
+	result := FindRegexpSubmatches(inputData, "Enclosure Device ID: (\\d+)\\nSlot Number: (\\d+)", -1)
+
 	data := []string{}
 
 	if len(result) > 0 {
@@ -42,21 +76,25 @@ func (v MegacliVendor) GetPhysicalDrivesIDs(controllerID string) []string {
 		}
 	}
 
-	return data
+	return data, nil
 }
 
 // GetControllerStatus - get controller status
-func (v MegacliVendor) GetControllerStatus(controllerID string, indent int) []byte {
-	type ReturnData struct {
-		Status        string `json:"status"`
-		Model         string `json:"model"`
-		BatteryStatus string `json:"batterystatus"`
+func (v MegacliVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	args := []string{"-AdpAllInfo", fmt.Sprintf("-a%s", controllerID), "-NoLog"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return Controller{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "-AdpAllInfo", fmt.Sprintf("-a%s", controllerID), "-NoLog")
 	model := GetRegexpSubmatch(inputData, "roduct Name[\\s]+: (.*)")
+	if len(model) == 0 {
+		return Controller{}, NewParseError("controller product name", v.execPath, args...)
+	}
 
 	healthStatuses := []string{}
+	state := StateOK
 	for _, v := range []string{
 		"Degraded",
 		"Offline",
@@ -67,6 +105,7 @@ func (v MegacliVendor) GetControllerStatus(controllerID string, indent int) []by
 
 		if TrimSpacesLeftAndRight(s) != "0" {
 			healthStatuses = append(healthStatuses, fmt.Sprintf("%s is %s", v, TrimSpacesLeftAndRight(s)))
+			state = state.Worse(megacliControllerCounters[v])
 		}
 	}
 
@@ -77,57 +116,70 @@ func (v MegacliVendor) GetControllerStatus(controllerID string, indent int) []by
 		status = strings.Join(healthStatuses, ", ")
 	}
 
-	inputData = GetCommandOutput(v.execPath, "-AdpBbuCmd", "-GetBbuStatus", fmt.Sprintf("-a%s", controllerID), "-NoLog")
+	inputData, err = v.runner.Run(v.execPath, "-AdpBbuCmd", "-GetBbuStatus", fmt.Sprintf("-a%s", controllerID), "-NoLog")
+	if err != nil {
+		return Controller{}, err
+	}
+
 	batteryStatus := GetRegexpSubmatch(inputData, "Battery State: (.*)")
 
-	data := ReturnData{
+	data := Controller{
 		Status:        TrimSpacesLeftAndRight(status),
+		Health:        NewHealth(state),
 		Model:         TrimSpacesLeftAndRight(model),
 		BatteryStatus: TrimSpacesLeftAndRight(batteryStatus),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
 // GetLDStatus - get logical drive status
-func (v MegacliVendor) GetLDStatus(controllerID string, deviceID string, indent int) []byte {
-	type ReturnData struct {
-		Status string `json:"status"`
-		Size   string `json:"size"`
+func (v MegacliVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	args := []string{"-LdInfo", fmt.Sprintf("-L%s", deviceID), fmt.Sprintf("-a%s", controllerID), "-NoLog"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return LogicalDrive{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "-LdInfo", fmt.Sprintf("-L%s", deviceID), fmt.Sprintf("-a%s", controllerID), "-NoLog")
 	status := GetRegexpSubmatch(inputData, "State *: (.*)")
+	if len(status) == 0 {
+		return LogicalDrive{}, NewParseError("logical drive status", v.execPath, args...)
+	}
 	size := GetRegexpSubmatch(inputData, "Size *: (.*)")
+	health := NewHealth(megacliLDStates.Get(status))
 
 	if status == "Optimal" {
 		status = "OK"
 	}
 
-	data := ReturnData{
+	data := LogicalDrive{
 		Status: TrimSpacesLeftAndRight(status),
+		Health: health,
 		Size:   TrimSpacesLeftAndRight(size),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
 // GetPDStatus - get physical drive status
-func (v MegacliVendor) GetPDStatus(controllerID string, deviceID string, indent int) []byte {
-	type ReturnData struct {
-		Status             string `json:"status"`
-		Model              string `json:"model"`
-		Size               string `json:"size"`
-		CurrentTemperature string `json:"currenttemperature"`
-		Smart              string `json:"smart"`
+func (v MegacliVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	args := []string{"-pdInfo", fmt.Sprintf("-PhysDrv[%s]", deviceID), fmt.Sprintf("-a%s", controllerID), "-NoLog"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return PhysicalDrive{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, "-pdInfo", fmt.Sprintf("-PhysDrv[%s]", deviceID), fmt.Sprintf("-a%s", controllerID), "-NoLog")
 	status := TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "Firmware state: (.*)"))
+	if len(status) == 0 {
+		return PhysicalDrive{}, NewParseError("physical drive status", v.execPath, args...)
+	}
 	model := GetRegexpSubmatch(inputData, "Inquiry Data: (.*)")
 	size := GetRegexpSubmatch(inputData, "Raw Size: (.*) \\[")
 	currentTemperature := GetRegexpSubmatch(inputData, "Drive Temperature :(\\d+)C")
 	smart := TrimSpacesLeftAndRight(GetRegexpSubmatch(inputData, "Drive has flagged a S.M.A.R.T alert : (.*)"))
+	health := NewHealth(megacliPDStates.Get(status))
 
 	if status == "Online, Spun Up" {
 		status = "OK"
@@ -137,18 +189,40 @@ func (v MegacliVendor) GetPDStatus(controllerID string, deviceID string, indent
 		smart = "OK"
 	}
 
-	data := ReturnData{
+	data := PhysicalDrive{
 		Status:             status,
+		Health:             health,
 		Model:              TrimSpacesLeftAndRight(model),
 		Size:               TrimSpacesLeftAndRight(size),
 		CurrentTemperature: TrimSpacesLeftAndRight(currentTemperature),
 		Smart:              smart,
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
+}
+
+// SmartDevices - drives behind MegaRAID controller are addressed by their device id on controller's SCSI host
+func (v MegacliVendor) SmartDevices(controllerID string, host ControllerHost) (map[string]SmartDevice, error) {
+	inputData, err := v.runner.Run(v.execPath, "-PDList", fmt.Sprintf("-a%s", controllerID), "-NoLog")
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := host.Host("megaraid_sas")
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]SmartDevice{}
+
+	for _, v := range FindRegexpSubmatches(inputData, "Enclosure Device ID: (\\d+)\\nSlot Number: (\\d+)\\n(?:.*\\n)*?Device Id: (\\d+)", -1) {
+		data[fmt.Sprintf("%s:%s", v[1], v[2])] = SmartDevice{Type: "megaraid," + v[3], Path: fmt.Sprintf("/dev/bus/%d", h.Number)}
+	}
+
+	return data, nil
 }
 
-func NewMegacliVendor(execPath string) Vendor {
-	v := MegacliVendor{execPath: execPath}
+func NewMegacliVendor(execPath string, runner Runner) Vendor {
+	v := MegacliVendor{execPath: execPath, runner: runner}
 	return v
 }
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsContentType - Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Label - metric label
+type Label struct {
+	Name  string
+	Value string
+}
+
+// MetricSample - value of metric with labels
+type MetricSample struct {
+	Labels []Label
+	Value  float64
+}
+
+// MetricFamily - metric with help, type and its samples
+type MetricFamily struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []MetricSample
+}
+
+// metricDefs - help of every exported metric, families are written in this order
+var metricDefs = []struct {
+	name string
+	help string
+}{
+	{"raid_collector_success", "Whether vendor controllers were listed successfully."},
+	{"raid_collector_duration_seconds", "Time spent collecting vendor status."},
+	{"raid_collector_last_success_timestamp_seconds", "Unix time vendor controllers were last listed successfully."},
+	{"raid_controller_up", "Whether controller status was read successfully."},
+	{"raid_controller_state", "Controller health state code: 0 ok, 1 warning, 2 critical, 3 unknown."},
+	{"raid_controller_temperature_celsius", "Controller temperature."},
+	{"raid_controller_size_bytes", "Controller (pool) size."},
+	{"raid_logical_drive_state", "Logical drive health state code: 0 ok, 1 warning, 2 critical, 3 unknown."},
+	{"raid_logical_drive_size_bytes", "Logical drive size."},
+	{"raid_physical_drive_state", "Physical drive health state code: 0 ok, 1 warning, 2 critical, 3 unknown."},
+	{"raid_physical_drive_temperature_celsius", "Physical drive temperature."},
+	{"raid_physical_drive_size_bytes", "Physical drive size."},
+}
+
+// metricSet - samples collected by metric name
+type metricSet map[string][]MetricSample
+
+// add - add sample of metric 'name', labels are name and value pairs
+func (m metricSet) add(name string, value float64, labels ...string) {
+	s := MetricSample{Value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		s.Labels = append(s.Labels, Label{Name: labels[i], Value: labels[i+1]})
+	}
+
+	m[name] = append(m[name], s)
+}
+
+// addValue - add sample parsed from status field, fields not reported or not parsed are skipped
+func (m metricSet) addValue(name string, parse func(string) (float64, bool), field string, labels ...string) {
+	if len(field) == 0 {
+		return
+	}
+
+	value, ok := parse(field)
+	if !ok {
+		logger.Debugf("%s %v: value '%s' is not parsed", name, labels, field)
+		return
+	}
+
+	m.add(name, value, labels...)
+}
+
+// families - metric families in metricDefs order, metrics without samples are skipped
+func (m metricSet) families() []MetricFamily {
+	var data []MetricFamily
+
+	for _, d := range metricDefs {
+		if len(m[d.name]) == 0 {
+			continue
+		}
+
+		data = append(data, MetricFamily{Name: d.name, Help: d.help, Type: "gauge", Samples: m[d.name]})
+	}
+
+	return data
+}
+
+// sizeValue - size with unit like '557.861 GB', '952720 MB' or '125034840 K', units are powers of 1024 as printed by RAID tools
+var sizeValue = regexp.MustCompile(`(?i)^\s*([\d.]+)\s*(bytes|b|k|kb|kib|m|mb|mib|g|gb|gib|t|tb|tib|p|pb|pib)\b`)
+
+var sizeUnits = map[string]float64{
+	"b":     1,
+	"bytes": 1,
+	"k":     1 << 10,
+	"kb":    1 << 10,
+	"kib":   1 << 10,
+	"m":     1 << 20,
+	"mb":    1 << 20,
+	"mib":   1 << 20,
+	"g":     1 << 30,
+	"gb":    1 << 30,
+	"gib":   1 << 30,
+	"t":     1 << 40,
+	"tb":    1 << 40,
+	"tib":   1 << 40,
+	"p":     1 << 50,
+	"pb":    1 << 50,
+	"pib":   1 << 50,
+}
+
+// parseSize - size in bytes, value without unit is not parsed as its unit is unknown
+func parseSize(s string) (float64, bool) {
+	m := sizeValue.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return math.Round(value * sizeUnits[strings.ToLower(m[2])]), true
+}
+
+// parseBytes - size of vendors reporting plain bytes
+func parseBytes(s string) (float64, bool) {
+	value, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	return float64(value), err == nil
+}
+
+// parseTemperature - temperature in Celsius like '34', '34 C' or '34C (93.20 F)'
+func parseTemperature(s string) (float64, bool) {
+	value, err := strconv.ParseFloat(GetRegexpSubmatch([]byte(s), `^\s*(-?[\d.]+)`), 64)
+	return value, err == nil
+}
+
+// CollectMetrics - walk all controllers and drives of vendors and build metrics, vendor is label of every metric
+func CollectMetrics(names []string, vendors map[string]Vendor) []MetricFamily {
+	return collectMetricSet(names, vendors).families()
+}
+
+// collectMetricSet - metrics of all controllers and drives of vendors
+func collectMetricSet(names []string, vendors map[string]Vendor) metricSet {
+	m := metricSet{}
+
+	for _, name := range names {
+		started := time.Now()
+
+		d, err := CollectDump(vendors[name])
+		if err != nil {
+			logger.Errorf("%s: %s", name, err)
+		}
+
+		m.add("raid_collector_success", boolValue(err == nil), "vendor", name)
+		m.add("raid_collector_duration_seconds", time.Since(started).Seconds(), "vendor", name)
+
+		size := parseSize
+		if vendorDefs[name].sizeBytes {
+			size = parseBytes
+		}
+
+		for _, ctID := range sortedKeys(d.Controllers) {
+			ct := d.Controllers[ctID]
+			labels := []string{"vendor", name, "controller", ctID}
+
+			// status is set by every vendor, it's empty only when controller status failed
+			m.add("raid_controller_up", boolValue(len(ct.Status) != 0), labels...)
+			m.add("raid_controller_state", float64(ct.StateCode), labels...)
+			m.addValue("raid_controller_temperature_celsius", parseTemperature, ct.Temperature, labels...)
+			m.addValue("raid_controller_size_bytes", size, ct.Size, labels...)
+
+			for _, ldID := range sortedKeys(ct.LogicalDrives) {
+				ld := ct.LogicalDrives[ldID]
+				labels := []string{"vendor", name, "controller", ctID, "ld", ldID}
+
+				m.add("raid_logical_drive_state", float64(ld.StateCode), labels...)
+				m.addValue("raid_logical_drive_size_bytes", size, ld.Size, labels...)
+			}
+
+			for _, pdID := range sortedKeys(ct.PhysicalDrives) {
+				pd := ct.PhysicalDrives[pdID]
+				labels := []string{"vendor", name, "controller", ctID, "pd", pdID}
+
+				temperature := pd.CurrentTemperature
+				if len(temperature) == 0 {
+					temperature = pd.Temperature
+				}
+
+				pdSize := pd.Size
+				if len(pdSize) == 0 {
+					pdSize = pd.TotalSize
+				}
+
+				m.add("raid_physical_drive_state", float64(pd.StateCode), labels...)
+				m.addValue("raid_physical_drive_temperature_celsius", parseTemperature, temperature, labels...)
+				m.addValue("raid_physical_drive_size_bytes", size, pdSize, labels...)
+			}
+		}
+	}
+
+	return m
+}
+
+// WriteMetrics - write metric families in Prometheus text exposition format
+func WriteMetrics(w io.Writer, families []MetricFamily) error {
+	b := bufio.NewWriter(w)
+
+	for _, f := range families {
+		fmt.Fprintf(b, "# HELP %s %s\n", f.Name, f.Help)
+		fmt.Fprintf(b, "# TYPE %s %s\n", f.Name, f.Type)
+
+		for _, s := range f.Samples {
+			b.WriteString(f.Name)
+
+			if len(s.Labels) > 0 {
+				labels := make([]string, len(s.Labels))
+				for i, l := range s.Labels {
+					labels[i] = fmt.Sprintf("%s=\"%s\"", l.Name, escapeLabelValue(l.Value))
+				}
+
+				fmt.Fprintf(b, "{%s}", strings.Join(labels, ","))
+			}
+
+			fmt.Fprintf(b, " %s\n", strconv.FormatFloat(s.Value, 'g', -1, 64))
+		}
+	}
+
+	return b.Flush()
+}
+
+// escapeLabelValue - escape backslash, double quote and line feed in label value
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// boolValue - 1 for true, 0 for false
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// sortedKeys - keys of map in ascending order
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
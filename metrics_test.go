@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollectMetrics(t *testing.T) {
+	names := []string{"megacli", "zfs", "nvme", "hp", "sas2ircu", "sas3ircu", "marvell", "storcli", "mdraid", "adaptec"}
+	vendors := map[string]Vendor{}
+	for _, name := range names {
+		vendors[name] = fixtureVendor(t, name)
+	}
+
+	families := CollectMetrics(names, vendors)
+
+	// duration differs on every run
+	var stable []MetricFamily
+	for _, f := range families {
+		if f.Name != "raid_collector_duration_seconds" {
+			stable = append(stable, f)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := WriteMetrics(&b, stable); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	compareGolden(t, filepath.Join("testdata", "golden", "metrics.prom"), b.Bytes())
+}
+
+func TestCollectMetricsErrors(t *testing.T) {
+	tt := findVendorTest(t, "megacli")
+
+	files := map[string]string{}
+	for key, file := range tt.commands {
+		files[key] = file
+	}
+	delete(files, "-pdInfo -PhysDrv[252:1] -a0 -NoLog")
+
+	m := collectMetricSet([]string{"megacli", "hp"}, map[string]Vendor{
+		"megacli": tt.newVendor(tt.execPath, FixtureRunner{Dir: tt.fixtures, Files: files}),
+		"hp":      NewHPVendor("ssacli", FixtureRunner{}),
+	})
+
+	states := m["raid_physical_drive_state"]
+	if len(states) != len(tt.pdStatus) {
+		t.Fatalf("got %d drive states, want %d", len(states), len(tt.pdStatus))
+	}
+
+	for _, s := range states {
+		want := float64(StateOK)
+		if s.Labels[2].Value == "252:1" {
+			want = float64(StateUnknown)
+		}
+
+		if s.Value != want {
+			t.Errorf("drive %s: got state %v, want %v", s.Labels[2].Value, s.Value, want)
+		}
+	}
+
+	// failed drive doesn't fail collector, failed controllers list does
+	for _, s := range m["raid_collector_success"] {
+		want := map[string]float64{"megacli": 1, "hp": 0}[s.Labels[0].Value]
+		if s.Value != want {
+			t.Errorf("vendor %s: got collector success %v, want %v", s.Labels[0].Value, s.Value, want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		want float64
+		ok   bool
+	}{
+		{"557.861 GB", 598998687678, true},
+		{"952720 MB", 998999326720, true},
+		{"3.637 TB", 3998923790221, true},
+		{"125034840 K", 128035676160, true},
+		{"122040 M", 127968215040, true},
+		{"512 Bytes", 512, true},
+		{"914573", 0, false},
+		{"1.5TiB", 1649267441664, true},
+		{"", 0, false},
+		{"N/A", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseSize(tt.s)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("size '%s': got %v, %t, want %v, %t", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseTemperature(t *testing.T) {
+	tests := []struct {
+		s    string
+		want float64
+		ok   bool
+	}{
+		{"34", 34, true},
+		{"34 C", 34, true},
+		{"34C (93.20 F)", 34, true},
+		{"-5", -5, true},
+		{"", 0, false},
+		{"N/A", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseTemperature(tt.s)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("temperature '%s': got %v, %t, want %v, %t", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	families := []MetricFamily{
+		{Name: "raid_controller_up", Help: "Whether controller status was read successfully.", Type: "gauge", Samples: []MetricSample{
+			{Labels: []Label{{"vendor", "hp"}, {"controller", "slot \"0\"\\a\nb"}}, Value: 1},
+			{Value: 0.5},
+		}},
+	}
+
+	var b bytes.Buffer
+	if err := WriteMetrics(&b, families); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := strings.Join([]string{
+		"# HELP raid_controller_up Whether controller status was read successfully.",
+		"# TYPE raid_controller_up gauge",
+		`raid_controller_up{vendor="hp",controller="slot \"0\"\\a\nb"} 1`,
+		"raid_controller_up 0.5",
+		"",
+	}, "\n")
+
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
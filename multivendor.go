@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// vendorIDSeparator - separates vendor name and controller ID in namespaced IDs ('megacli:0')
+const vendorIDSeparator = ":"
+
+// MultiVendor - combines several vendors, controller IDs are namespaced with vendor name
+type MultiVendor struct {
+	names   []string
+	vendors map[string]Vendor
+	onError func(error)
+}
+
+// GetControllersIDs - get controllers of all vendors, failing vendors are reported and skipped
+func (m MultiVendor) GetControllersIDs() ([]string, error) {
+	var (
+		data []string
+		errs []string
+	)
+
+	for _, name := range m.names {
+		controllersIDs, err := m.vendors[name].GetControllersIDs()
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			errs = append(errs, err.Error())
+			m.onError(err)
+			continue
+		}
+
+		for _, ctID := range controllersIDs {
+			data = append(data, JoinControllerID(name, ctID))
+		}
+	}
+
+	if len(errs) == len(m.names) {
+		return nil, fmt.Errorf("all vendors failed: %s", strings.Join(errs, "; "))
+	}
+
+	return data, nil
+}
+
+// GetLogicalDrivesIDs - get number of logical drives for namespaced controller ID
+func (m MultiVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	v, ctID, err := m.vendor(controllerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.GetLogicalDrivesIDs(ctID)
+}
+
+// GetPhysicalDrivesIDs - get number of physical drives for namespaced controller ID
+func (m MultiVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	v, ctID, err := m.vendor(controllerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.GetPhysicalDrivesIDs(ctID)
+}
+
+// GetControllerStatus - get controller status for namespaced controller ID
+func (m MultiVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	v, ctID, err := m.vendor(controllerID)
+	if err != nil {
+		return Controller{}, err
+	}
+
+	return v.GetControllerStatus(ctID)
+}
+
+// GetLDStatus - get logical drive status for namespaced controller ID
+func (m MultiVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	v, ctID, err := m.vendor(controllerID)
+	if err != nil {
+		return LogicalDrive{}, err
+	}
+
+	return v.GetLDStatus(ctID, deviceID)
+}
+
+// GetPDStatus - get physical drive status for namespaced controller ID
+func (m MultiVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	v, ctID, err := m.vendor(controllerID)
+	if err != nil {
+		return PhysicalDrive{}, err
+	}
+
+	return v.GetPDStatus(ctID, deviceID)
+}
+
+// vendor - find vendor and its own controller ID by namespaced controller ID
+func (m MultiVendor) vendor(controllerID string) (Vendor, string, error) {
+	name, ctID, ok := SplitControllerID(controllerID)
+	if !ok {
+		return nil, "", fmt.Errorf("controller id '%s' must be prefixed with vendor name (ex.: megacli%s0)", controllerID, vendorIDSeparator)
+	}
+
+	v, ok := m.vendors[name]
+	if !ok {
+		return nil, "", fmt.Errorf("vendor '%s' of controller '%s' is not selected", name, controllerID)
+	}
+
+	return v, ctID, nil
+}
+
+// JoinControllerID - namespace controller ID with vendor name
+func JoinControllerID(vendor string, controllerID string) string {
+	return vendor + vendorIDSeparator + controllerID
+}
+
+// SplitControllerID - split namespaced controller ID, 'ok' is false when ID has no known vendor prefix
+func SplitControllerID(controllerID string) (vendor string, ctID string, ok bool) {
+	parts := strings.SplitN(controllerID, vendorIDSeparator, 2)
+	if len(parts) != 2 {
+		return "", controllerID, false
+	}
+
+	if _, known := vendorDefs[parts[0]]; !known {
+		return "", controllerID, false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// NewMultiVendor - combine 'vendors' queried in 'names' order, 'onError' receives errors of skipped vendors
+func NewMultiVendor(names []string, vendors map[string]Vendor, onError func(error)) Vendor {
+	m := MultiVendor{names: names, vendors: vendors, onError: onError}
+	return m
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fixtureVendor - vendor from vendorTests running against its fixtures
+func fixtureVendor(t *testing.T, name string) Vendor {
+	t.Helper()
+
+	for _, tt := range vendorTests {
+		if tt.name == name {
+			return tt.vendor()
+		}
+	}
+
+	t.Fatalf("no fixtures for vendor '%s'", name)
+	return nil
+}
+
+func TestMultiVendor(t *testing.T) {
+	var reported []error
+
+	names := []string{"hp", "megacli", "sas2ircu"}
+	m := NewMultiVendor(names, map[string]Vendor{
+		"hp":       fixtureVendor(t, "hp"),
+		"megacli":  NewMegacliVendor("megacli", FixtureRunner{}),
+		"sas2ircu": fixtureVendor(t, "sas2ircu"),
+	}, func(err error) { reported = append(reported, err) })
+
+	ids, err := m.GetControllersIDs()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := []string{"hp:0", "sas2ircu:0"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got controllers %q, want %q", ids, want)
+	}
+
+	if len(reported) != 1 {
+		t.Errorf("expected failing megacli to be reported once, got %q", reported)
+	}
+
+	lds, err := m.GetLogicalDrivesIDs("sas2ircu:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := []string{"1", "2"}; !reflect.DeepEqual(lds, want) {
+		t.Errorf("got logical drives %q, want %q", lds, want)
+	}
+
+	ct, err := m.GetControllerStatus("hp:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if ct.Model != "Smart Array P410i" {
+		t.Errorf("got model '%s', want 'Smart Array P410i'", ct.Model)
+	}
+
+	for _, id := range []string{"0", "adaptec:1", "dell:0"} {
+		if _, err := m.GetControllerStatus(id); err == nil {
+			t.Errorf("controller '%s': expected error, got nil", id)
+		}
+	}
+}
+
+func TestMultiVendorAllFailed(t *testing.T) {
+	m := NewMultiVendor([]string{"hp"}, map[string]Vendor{
+		"hp": NewHPVendor("ssacli", FixtureRunner{}),
+	}, func(error) {})
+
+	if _, err := m.GetControllersIDs(); err == nil {
+		t.Error("expected error when all vendors failed, got nil")
+	}
+}
+
+func TestSplitControllerID(t *testing.T) {
+	tests := []struct {
+		id     string
+		vendor string
+		ctID   string
+		ok     bool
+	}{
+		{"megacli:0", "megacli", "0", true},
+		{"sas2ircu:1", "sas2ircu", "1", true},
+		{"0", "", "0", false},
+		{"dell:0", "", "dell:0", false},
+	}
+
+	for _, tt := range tests {
+		vendor, ctID, ok := SplitControllerID(tt.id)
+		if vendor != tt.vendor || ctID != tt.ctID || ok != tt.ok {
+			t.Errorf("SplitControllerID(%q) = %q, %q, %v, want %q, %q, %v", tt.id, vendor, ctID, ok, tt.vendor, tt.ctID, tt.ok)
+		}
+	}
+}
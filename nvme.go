@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// nvmeCriticalWarnings - critical warning bits of SMART / health log and state they set
+var nvmeCriticalWarnings = []struct {
+	status string
+	state  State
+}{
+	{"available spare below threshold", StateWarning},
+	{"temperature threshold exceeded", StateWarning},
+	{"reliability degraded", StateCritical},
+	{"read only", StateCritical},
+	{"volatile memory backup failed", StateWarning},
+	{"persistent memory region read only", StateWarning},
+}
+
+// nvmeKelvin - temperatures in smart-log are in kelvins
+const nvmeKelvin = 273
+
+// nvmeNamespaceName - namespace block device 'nvme0n1', first group is its controller
+var nvmeNamespaceName = regexp.MustCompile(`^(nvme\d+)n\d+$`)
+
+// nvmeNamespace - entry of 'nvme list' devices, same in nvme-cli 1.x and 2.x
+type nvmeNamespace struct {
+	DevicePath   string `json:"DevicePath"`
+	Firmware     string `json:"Firmware"`
+	ModelNumber  string `json:"ModelNumber"`
+	PhysicalSize int64  `json:"PhysicalSize"`
+}
+
+// name - namespace block device name
+func (n nvmeNamespace) name() string {
+	return filepath.Base(n.DevicePath)
+}
+
+// controller - name of controller of namespace
+func (n nvmeNamespace) controller() string {
+	if m := nvmeNamespaceName.FindStringSubmatch(n.name()); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
+
+// nvmeSmartLog - SMART / health information log of controller
+type nvmeSmartLog struct {
+	// number in nvme-cli 1.x and 2.x, object with 'value' in newer versions
+	CriticalWarning json.RawMessage `json:"critical_warning"`
+	Temperature     int64           `json:"temperature"`
+	AvailSpare      int64           `json:"avail_spare"`
+	SpareThresh     int64           `json:"spare_thresh"`
+	PercentUsed     int64           `json:"percent_used"`
+	PowerOnHours    int64           `json:"power_on_hours"`
+	MediaErrors     int64           `json:"media_errors"`
+	warning         int64
+}
+
+// criticalWarning - critical warning bits
+func (l nvmeSmartLog) criticalWarning() (int64, error) {
+	var value int64
+	if err := json.Unmarshal(l.CriticalWarning, &value); err == nil {
+		return value, nil
+	}
+
+	var object struct {
+		Value *int64 `json:"value"`
+	}
+	if err := json.Unmarshal(l.CriticalWarning, &object); err != nil || object.Value == nil {
+		return 0, fmt.Errorf("wrong critical warning '%s'", l.CriticalWarning)
+	}
+
+	return *object.Value, nil
+}
+
+// health - status and state from critical warning bits and used endurance
+func (l nvmeSmartLog) health() (string, State) {
+	healthStatuses := []string{}
+	state := StateOK
+
+	for bit, w := range nvmeCriticalWarnings {
+		if l.warning&(1<<bit) != 0 {
+			healthStatuses = append(healthStatuses, w.status)
+			state = state.Worse(w.state)
+		}
+	}
+
+	if l.PercentUsed >= 100 {
+		healthStatuses = append(healthStatuses, "endurance used up")
+		state = state.Worse(StateWarning)
+	}
+
+	if len(healthStatuses) == 0 {
+		return "OK", state
+	}
+
+	return strings.Join(healthStatuses, "; "), state
+}
+
+type NVMeVendor struct {
+	execPath string
+	runner   Runner
+}
+
+// namespaces - namespaces from 'nvme list'
+func (v NVMeVendor) namespaces() ([]nvmeNamespace, error) {
+	args := []string{"list", "-o", "json"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// nvme-cli prints nothing when there are no devices
+	if len(strings.TrimSpace(string(inputData))) == 0 {
+		return nil, nil
+	}
+
+	var data struct {
+		Devices []nvmeNamespace `json:"Devices"`
+	}
+
+	if err := json.Unmarshal(inputData, &data); err != nil {
+		command := commandLine(v.execPath, args)
+		return nil, &CommandError{Code: ErrorCodeParse, Command: command, Err: fmt.Errorf("error parsing JSON output of command '%s': %w", command, err)}
+	}
+
+	return data.Devices, nil
+}
+
+// namespace - namespace 'name' of controller 'controllerID'
+func (v NVMeVendor) namespace(controllerID string, name string) (nvmeNamespace, error) {
+	namespaces, err := v.namespaces()
+	if err != nil {
+		return nvmeNamespace{}, err
+	}
+
+	for _, n := range namespaces {
+		if n.controller() == controllerID && n.name() == name {
+			return n, nil
+		}
+	}
+
+	return nvmeNamespace{}, NewUnknownDeviceError("namespace '%s' not found on controller '%s'", name, controllerID)
+}
+
+// smartLog - SMART / health log of controller 'controllerID'
+func (v NVMeVendor) smartLog(controllerID string) (nvmeSmartLog, error) {
+	args := []string{"smart-log", "/dev/" + controllerID, "-o", "json"}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return nvmeSmartLog{}, err
+	}
+
+	var data nvmeSmartLog
+	if err := json.Unmarshal(inputData, &data); err != nil {
+		command := commandLine(v.execPath, args)
+		return nvmeSmartLog{}, &CommandError{Code: ErrorCodeParse, Command: command, Err: fmt.Errorf("error parsing JSON output of command '%s': %w", command, err)}
+	}
+
+	if data.warning, err = data.criticalWarning(); err != nil {
+		return nvmeSmartLog{}, NewParseError("critical warning", v.execPath, args...)
+	}
+
+	return data, nil
+}
+
+// GetControllersIDs - get NVMe controllers with namespaces ('nvme0')
+func (v NVMeVendor) GetControllersIDs() ([]string, error) {
+	namespaces, err := v.namespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+	seen := map[string]bool{}
+
+	for _, n := range namespaces {
+		if c := n.controller(); len(c) != 0 && !seen[c] {
+			seen[c] = true
+			data = append(data, c)
+		}
+	}
+
+	return data, nil
+}
+
+// GetLogicalDrivesIDs - NVMe controllers have no logical drives
+func (v NVMeVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	return []string{}, nil
+}
+
+// GetPhysicalDrivesIDs - get namespaces of controller with ID 'controllerID' ('nvme0n1')
+func (v NVMeVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	namespaces, err := v.namespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, n := range namespaces {
+		if n.controller() == controllerID {
+			data = append(data, n.name())
+		}
+	}
+
+	return data, nil
+}
+
+// GetControllerStatus - get controller health from its SMART / health log
+func (v NVMeVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	namespaces, err := v.namespaces()
+	if err != nil {
+		return Controller{}, err
+	}
+
+	var model string
+	for _, n := range namespaces {
+		if n.controller() == controllerID {
+			model = TrimSpacesLeftAndRight(n.ModelNumber)
+			break
+		}
+	}
+
+	if len(model) == 0 {
+		return Controller{}, NewUnknownDeviceError("controller '%s' not found", controllerID)
+	}
+
+	smartLog, err := v.smartLog(controllerID)
+	if err != nil {
+		return Controller{}, err
+	}
+
+	status, state := smartLog.health()
+
+	data := Controller{
+		Status:      status,
+		Health:      NewHealth(state),
+		Model:       model,
+		Temperature: strconv.FormatInt(smartLog.Temperature-nvmeKelvin, 10),
+	}
+
+	return data, nil
+}
+
+// GetLDStatus - NVMe controllers have no logical drives
+func (v NVMeVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	return LogicalDrive{}, NewUnknownDeviceError("NVMe controller '%s' has no logical drives", controllerID)
+}
+
+// GetPDStatus - get namespace status, health is controller's SMART / health log
+func (v NVMeVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	n, err := v.namespace(controllerID, deviceID)
+	if err != nil {
+		return PhysicalDrive{}, err
+	}
+
+	smartLog, err := v.smartLog(controllerID)
+	if err != nil {
+		return PhysicalDrive{}, err
+	}
+
+	status, state := smartLog.health()
+
+	data := PhysicalDrive{
+		Status:                  status,
+		Health:                  NewHealth(state),
+		Model:                   TrimSpacesLeftAndRight(n.ModelNumber),
+		FirmwareVersion:         TrimSpacesLeftAndRight(n.Firmware),
+		Size:                    strconv.FormatInt(n.PhysicalSize, 10),
+		CurrentTemperature:      strconv.FormatInt(smartLog.Temperature-nvmeKelvin, 10),
+		PowerOnHours:            strconv.FormatInt(smartLog.PowerOnHours, 10),
+		MediaErrors:             strconv.FormatInt(smartLog.MediaErrors, 10),
+		WearLevel:               strconv.FormatInt(smartLog.PercentUsed, 10),
+		CriticalWarning:         fmt.Sprintf("0x%02x", smartLog.warning),
+		AvailableSpare:          strconv.FormatInt(smartLog.AvailSpare, 10),
+		AvailableSpareThreshold: strconv.FormatInt(smartLog.SpareThresh, 10),
+	}
+
+	return data, nil
+}
+
+// NewNVMeVendor - NVMe drives managed by nvme-cli, controllers are NVMe controllers and physical drives their namespaces
+func NewNVMeVendor(execPath string, runner Runner) Vendor {
+	v := NVMeVendor{execPath: execPath, runner: runner}
+	return v
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const manifestFile = "manifest.json"
+
+// Manifest - index of recorded tool output, 'Files' and 'Errors' are keyed by space-joined arguments like FixtureRunner,
+// commands of other tools run for vendor (smartctl) are keyed by tool name and arguments
+type Manifest struct {
+	Created    string                    `json:"created"`
+	Anonymized bool                      `json:"anonymized"`
+	Vendors    map[string]ManifestVendor `json:"vendors"`
+}
+
+// ManifestVendor - recorded commands of vendor tool 'Binary', file names are relative to vendor directory
+type ManifestVendor struct {
+	Binary string                   `json:"binary"`
+	Files  map[string]string        `json:"files"`
+	Errors map[string]ManifestError `json:"errors,omitempty"`
+}
+
+// ManifestError - recorded command failure
+type ManifestError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Recorder - keeps output of every command run through its runners and writes it to 'Dir'
+// as '<vendor>/<file>.txt' with manifest, same layout as testdata
+type Recorder struct {
+	Dir       string
+	Anonymize bool
+	commands  []recordedCommand
+}
+
+type recordedCommand struct {
+	vendor string
+	binary string
+	key    string
+	args   []string
+	output []byte
+	err    error
+}
+
+// RecordingRunner - runs commands with 'Runner' and passes their output to recorder
+type RecordingRunner struct {
+	Runner   Runner
+	vendor   string
+	binary   string
+	recorder *Recorder
+}
+
+// Run - run command and record its output or error
+func (r RecordingRunner) Run(execPath string, args ...string) ([]byte, error) {
+	data, err := r.Runner.Run(execPath, args...)
+	r.recorder.add(r.vendor, r.binary, recordKey(r.binary, execPath, args), args, data, err)
+	return data, err
+}
+
+// Runner - wrap runner of vendor with tool 'binary' to record its commands
+func (r *Recorder) Runner(vendor string, binary string, runner Runner) Runner {
+	return RecordingRunner{Runner: runner, vendor: vendor, binary: binary, recorder: r}
+}
+
+// recordKey - manifest key of command, arguments for vendor tool 'binary' and tool name with arguments for other tools
+func recordKey(binary string, execPath string, args []string) string {
+	if execPath == binary {
+		return strings.Join(args, " ")
+	}
+
+	return commandLine(filepath.Base(execPath), args)
+}
+
+// add - record command, only first run of same key is kept
+func (r *Recorder) add(vendor string, binary string, key string, args []string, output []byte, err error) {
+	for _, c := range r.commands {
+		if c.vendor == vendor && c.key == key {
+			return
+		}
+	}
+
+	r.commands = append(r.commands, recordedCommand{vendor: vendor, binary: binary, key: key, args: args, output: output, err: err})
+}
+
+// Write - write recorded output and manifest
+func (r *Recorder) Write() (Manifest, error) {
+	m := Manifest{
+		Created:    time.Now().UTC().Format(time.RFC3339),
+		Anonymized: r.Anonymize,
+		Vendors:    map[string]ManifestVendor{},
+	}
+
+	var anonymizer *Anonymizer
+	if r.Anonymize {
+		anonymizer = NewAnonymizer()
+		for _, c := range r.commands {
+			anonymizer.Collect(c.output)
+		}
+	}
+
+	for _, c := range r.commands {
+		mv, ok := m.Vendors[c.vendor]
+		if !ok {
+			mv = ManifestVendor{Binary: c.binary, Files: map[string]string{}}
+		}
+
+		if c.err != nil {
+			if mv.Errors == nil {
+				mv.Errors = map[string]ManifestError{}
+			}
+
+			report := NewErrorReport(c.err, c.vendor)
+			if anonymizer != nil {
+				report.Error.Message = string(anonymizer.Replace([]byte(report.Error.Message)))
+			}
+
+			mv.Errors[c.key] = ManifestError{Code: report.Error.Code, Message: report.Error.Message}
+			m.Vendors[c.vendor] = mv
+			continue
+		}
+
+		output := c.output
+		if anonymizer != nil {
+			output = anonymizer.Replace(output)
+		}
+
+		name := recordFileName([]string{c.key}, mv.Files)
+		path := filepath.Join(r.Dir, c.vendor, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return Manifest{}, err
+		}
+
+		if err := os.WriteFile(path, output, 0644); err != nil {
+			return Manifest{}, err
+		}
+
+		mv.Files[c.key] = name
+		m.Vendors[c.vendor] = mv
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error marshalling JSON: %w", err)
+	}
+
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return Manifest{}, err
+	}
+
+	if err := os.WriteFile(filepath.Join(r.Dir, manifestFile), append(data, "\n"...), 0644); err != nil {
+		return Manifest{}, err
+	}
+
+	return m, nil
+}
+
+var recordFileNameSpecial = regexp.MustCompile("[^A-Za-z0-9.=]+")
+
+// recordFileName - file name for command arguments not used by other 'files' ('-LdInfo -L0 -a0' is 'LdInfo_L0_a0.txt')
+func recordFileName(args []string, files map[string]string) string {
+	base := strings.Trim(recordFileNameSpecial.ReplaceAllString(strings.Join(args, " "), "_"), "_")
+	if len(base) == 0 {
+		base = "noargs"
+	}
+
+	used := map[string]bool{}
+	for _, f := range files {
+		used[f] = true
+	}
+
+	name := base + ".txt"
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s_%d.txt", base, i)
+	}
+
+	return name
+}
+
+// anonymizedFields - labeled values identifying hardware: serial numbers, WWNs, SAS addresses and GUIDs
+var anonymizedFields = regexp.MustCompile(`(?im)^[ \t]*(?:[\w()/]+[ \t]+)*?(?:serial(?:[ \t]?(?:no|number))?|wwn|world[ \t]wide[ \t](?:name|id)|(?:sas|wwid)[ \t]address(?:\(\d+\))?|guid)[ \t]*[:=][ \t]*(\S[^\r\n]*?)[ \t]*$`)
+
+// anonymizedJSONFields - same identifiers as JSON string values of tools with JSON output (storcli 'SN', 'SAS Address', smartctl 'logical_unit_id')
+var anonymizedJSONFields = regexp.MustCompile(`(?i)"(?:sn|serial(?:[ _]?(?:no|number))?|wwn|world[ _]wide[ _](?:name|id)|(?:sas|wwid)[ _]address(?:\(\d+\))?|guid|logical_unit_id)"[ \t]*:[ \t]*"[ \t]*([^"]*?)[ \t]*"`)
+
+// diskIDSerial - serial number in /dev/disk/by-id name of disk ('ata-ST4000NM0035-1V4107_ZC1A2B3C-part1') as zpool status prints it
+var diskIDSerial = regexp.MustCompile(`\b(?:ata|scsi|nvme|usb|sas)-(?:[\w.-]+?_)+([A-Za-z0-9]+)(?:-part\d+)?(?:[^\w-]|$)`)
+
+// diskIDWWN - WWN or EUI in /dev/disk/by-id name of disk ('wwn-0x5000c500a1b2c301', 'scsi-35000c500a1b2c301', 'nvme-eui.0025388b01234567')
+var diskIDWWN = regexp.MustCompile(`(?i)\b(?:wwn-0x|scsi-[0-9]|eui\.)([0-9a-f]{8,})\b`)
+
+// zpoolGUID - vdev GUID printed by zpool status instead of name of missing device
+var zpoolGUID = regexp.MustCompile(`(?m)^[ \t]+(\d{10,20})[ \t]+[A-Z]+\b`)
+
+// smartctlJSONIDs - unique part of WWN and EUI-64 objects of smartctl JSON ('"wwn": {"naa": 5, "oui": 3152, "id": 2712646402}')
+var smartctlJSONIDs = regexp.MustCompile(`"(?:wwn|eui64)"[ \t]*:[ \t]*\{[^}]*?"(?:id|ext_id)"[ \t]*:[ \t]*(\d+)`)
+
+// inquiryData - megacli SCSI inquiry string with serial number inside
+var inquiryData = regexp.MustCompile(`(?m)^[ \t]*Inquiry Data:[ \t]*([^\r\n]*?)[ \t]*$`)
+
+// anonymizedPlaceholders - values that don't identify anything
+var anonymizedPlaceholders = map[string]bool{"n/a": true, "na": true, "none": true, "unknown": true, "not available": true}
+
+// Anonymizer - replaces identifiers with fake values of the same shape, same value always gets same fake
+type Anonymizer struct {
+	fakes map[string]string
+}
+
+// NewAnonymizer - create anonymizer without known identifiers
+func NewAnonymizer() *Anonymizer {
+	return &Anonymizer{fakes: map[string]string{}}
+}
+
+// Collect - find identifiers in tool output, must be called for all output before Replace
+func (a *Anonymizer) Collect(data []byte) {
+	for _, m := range anonymizedFields.FindAllSubmatch(data, -1) {
+		a.add(string(m[1]))
+	}
+
+	for _, m := range anonymizedJSONFields.FindAllSubmatch(data, -1) {
+		a.add(string(m[1]))
+	}
+
+	for _, re := range []*regexp.Regexp{diskIDSerial, diskIDWWN, zpoolGUID, smartctlJSONIDs} {
+		for _, m := range re.FindAllSubmatch(data, -1) {
+			a.add(string(m[1]))
+		}
+	}
+
+	for _, m := range inquiryData.FindAllSubmatch(data, -1) {
+		a.add(inquirySerial(string(m[1])))
+	}
+}
+
+// Replace - replace all collected identifiers in data
+func (a *Anonymizer) Replace(data []byte) []byte {
+	values := make([]string, 0, len(a.fakes))
+	for v := range a.fakes {
+		values = append(values, v)
+	}
+
+	// longest first, so value containing another one is replaced whole
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+
+	pairs := make([]string, 0, len(values)*2)
+	for _, v := range values {
+		pairs = append(pairs, v, a.fakes[v])
+	}
+
+	return []byte(strings.NewReplacer(pairs...).Replace(string(data)))
+}
+
+// add - assign fake to identifier, placeholders and zero values are skipped
+func (a *Anonymizer) add(value string) {
+	value = strings.TrimSpace(value)
+
+	if len(value) < 4 || anonymizedPlaceholders[strings.ToLower(value)] || strings.Trim(strings.ToLower(value), "0x-: ") == "" {
+		return
+	}
+
+	if _, ok := a.fakes[value]; ok {
+		return
+	}
+
+	a.fakes[value] = fakeIdentifier(value, len(a.fakes)+1)
+}
+
+// fakeIdentifier - value of the same length and separators with sequence number 'n' at the end,
+// hex values stay hex ('0x5000c500540ffa89' is '0x0000000000000001'), decimal numbers stay numbers ('2712646402' is '1000000001')
+func fakeIdentifier(value string, n int) string {
+	var prefix string
+	if strings.HasPrefix(strings.ToLower(value), "0x") {
+		prefix, value = value[:2], value[2:]
+	}
+
+	hex := strings.Trim(strings.ToLower(value), "0123456789abcdef-:") == ""
+	num := strconv.Itoa(n)
+	fake := []byte(value)
+
+	for i, j := len(fake)-1, len(num)-1; i >= 0; i-- {
+		c := fake[i]
+
+		switch {
+		case !isAlphanumeric(c):
+			continue
+		case j >= 0:
+			fake[i] = num[j]
+			j--
+		case hex || c >= '0' && c <= '9':
+			fake[i] = '0'
+		default:
+			fake[i] = 'X'
+		}
+	}
+
+	// decimal numbers don't start with zero, so numbers of JSON output stay valid
+	if len(prefix) == 0 && len(fake) > 1 && fake[0] == '0' && strings.Trim(value, "0123456789") == "" {
+		fake[0] = '1'
+	}
+
+	return prefix + string(fake)
+}
+
+// inquirySerial - serial number in SCSI inquiry string: first 20 characters for SATA drives (serial, model, firmware),
+// characters after vendor, product and revision (8+16+4) for SAS drives
+func inquirySerial(inquiry string) string {
+	if len(inquiry) >= 60 {
+		return strings.TrimSpace(inquiry[:20])
+	}
+
+	if len(inquiry) > 28 {
+		return strings.TrimSpace(inquiry[28:])
+	}
+
+	return ""
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	var tt vendorTest
+	for _, v := range vendorTests {
+		if v.name == "megacli" {
+			tt = v
+		}
+	}
+
+	dir := t.TempDir()
+	r := &Recorder{Dir: dir}
+
+	// drive 252:1 has no fixture, its failed command must be recorded as error
+	failed := "-pdInfo -PhysDrv[252:1] -a0 -NoLog"
+	files := map[string]string{}
+	for key, file := range tt.commands {
+		if key != failed {
+			files[key] = file
+		}
+	}
+
+	v := tt.newVendor(tt.execPath, r.Runner(tt.name, tt.execPath, FixtureRunner{Dir: tt.fixtures, Files: files}))
+	if _, err := CollectDump(v); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	m, err := r.Write()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	mv := m.Vendors[tt.name]
+	if mv.Binary != tt.execPath {
+		t.Errorf("got binary '%s', want '%s'", mv.Binary, tt.execPath)
+	}
+
+	for key, file := range files {
+		name, ok := mv.Files[key]
+		if !ok {
+			t.Errorf("command '%s' not recorded", key)
+			continue
+		}
+
+		want, _ := os.ReadFile(filepath.Join(tt.fixtures, file))
+		got, err := os.ReadFile(filepath.Join(dir, tt.name, name))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("recorded output of '%s' differs from fixture %s (%v)", key, file, err)
+		}
+	}
+
+	if _, ok := mv.Errors[failed]; !ok {
+		t.Errorf("failed command not recorded in errors: %v", mv.Errors)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var written Manifest
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("error reading manifest: %s", err)
+	}
+
+	if len(written.Vendors[tt.name].Files) != len(files) {
+		t.Errorf("manifest has %d files, want %d", len(written.Vendors[tt.name].Files), len(files))
+	}
+}
+
+func TestRecordFileName(t *testing.T) {
+	files := map[string]string{"-LdInfo -L0 -a0": "LdInfo_L0_a0.txt"}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-pdInfo", "-PhysDrv[252:0]", "-a0", "-NoLog"}, "pdInfo_PhysDrv_252_0_a0_NoLog.txt"},
+		{[]string{"ctrl", "slot=0", "show", "status"}, "ctrl_slot=0_show_status.txt"},
+		{[]string{"-LdInfo", "-L0", "-a0"}, "LdInfo_L0_a0_2.txt"},
+		{nil, "noargs.txt"},
+	}
+
+	for _, tt := range tests {
+		if got := recordFileName(tt.args, files); got != tt.want {
+			t.Errorf("recordFileName(%q) = '%s', want '%s'", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestAnonymizer(t *testing.T) {
+	first := []byte(`Serial No       : SV21414201
+SAS Address(0): 0x5000c50054102dad
+SAS Address(1): 0x0
+WWN: 5000C50054102DAC
+  SAS Address                             : 4433221-1-0300-0000
+Serial Debugger  : Present
+GUID                                    : N/A
+Inquiry Data: S2HTNX0H509266      SAMSUNG MZ7KM960HAHP-00005              GXM1003Q
+Inquiry Data: SEAGATE ST9300605SS     00026XP3CGMN
+		"SN" : "ZC20ABCD",
+		"SAS Address" : " 500605b00d2c5a80",
+		"SN" : "N/A",
+`)
+	second := []byte("Attached SAS Address            : 4433221-1-0300-0000\nSerial:              SV21414201\n")
+
+	a := NewAnonymizer()
+	a.Collect(first)
+	a.Collect(second)
+
+	got := string(a.Replace(first)) + string(a.Replace(second))
+
+	for _, leaked := range []string{"SV21414201", "5000c50054102dad", "5000C50054102DAC", "4433221-1-0300-0000", "S2HTNX0H509266", "6XP3CGMN", "ZC20ABCD", "500605b00d2c5a80"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("identifier '%s' not replaced:\n%s", leaked, got)
+		}
+	}
+
+	for _, kept := range []string{"SAS Address(1): 0x0", "Serial Debugger  : Present", ": N/A", "SAMSUNG MZ7KM960HAHP-00005", "SEAGATE ST9300605SS     0002", `"SN" : "N/A"`} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("'%s' must be kept:\n%s", kept, got)
+		}
+	}
+
+	fake := a.fakes["4433221-1-0300-0000"]
+	if strings.Count(got, fake) != 2 {
+		t.Errorf("same SAS address must get same fake '%s' in all output:\n%s", fake, got)
+	}
+}
+
+func TestAnonymizerZpoolStatus(t *testing.T) {
+	var data []byte
+	for _, f := range []string{"rpoolStatus.txt", "tankStatus.txt"} {
+		d, err := os.ReadFile(filepath.Join("testdata", "zfs", f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, d...)
+	}
+	data = append(data, "\tscsi-35000c500a1b2c3aa  ONLINE  0 0 0\n\tnvme-eui.0025388b01234567  ONLINE  0 0 0\n"...)
+
+	a := NewAnonymizer()
+	a.Collect(data)
+	got := string(a.Replace(data))
+
+	for _, leaked := range []string{"5000c500a1b2c30", "S45PNA0M51234", "9876543210987654321", "5000c500a1b2c3aa", "0025388b01234567"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("identifier '%s' not replaced:\n%s", leaked, got)
+		}
+	}
+
+	for _, kept := range []string{"ata-SAMSUNG_MZ7LH480HAHQ-00005_", "-part3", "wwn-0x", "raidz2-0", "replacing-2", "mirror-1", "nvme0n1p1", "14502305628160 scanned"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("'%s' must be kept:\n%s", kept, got)
+		}
+	}
+
+	// replaced device and its former name keep pointing at the same fake
+	if fake := a.fakes["5000c500a1b2c303"]; len(fake) == 0 || !strings.Contains(got, "was /dev/disk/by-id/wwn-0x"+fake+"-part1") {
+		t.Errorf("former device name not anonymized:\n%s", got)
+	}
+}
+
+func TestAnonymizerSmartctl(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "smart", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no smartctl fixtures: %v", err)
+	}
+
+	a := NewAnonymizer()
+
+	var outputs [][]byte
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data = append(data, `{"logical_unit_id": "0x5000c500a1b2c3d4", "eui64": {"oui": 9528, "ext_id": 775744079205}}`...)
+		a.Collect(data)
+		outputs = append(outputs, data)
+	}
+
+	for i, data := range outputs {
+		got := a.Replace(data)
+
+		for _, leaked := range []string{"2712646402", "ZC1A2B3C", "KXJ5A1BC", "S64HNE0R501234", "S45NNA0M812345", "5000c500a1b2c3d4", "775744079205"} {
+			if strings.Contains(string(got), leaked) {
+				t.Errorf("%s: identifier '%s' not replaced", files[i], leaked)
+			}
+		}
+
+		// fixture part is still valid JSON parsed by SMART enrichment
+		var output smartctlOutput
+		if err := json.NewDecoder(bytes.NewReader(got)).Decode(&output); err != nil {
+			t.Errorf("%s: anonymized output is not valid JSON: %s", files[i], err)
+		}
+	}
+}
+
+func TestFakeIdentifier(t *testing.T) {
+	tests := []struct {
+		value string
+		n     int
+		want  string
+	}{
+		{"0x5000c500540ffa89", 3, "0x0000000000000003"},
+		{"4433221-1-0300-0000", 12, "0000000-0-0000-0012"},
+		{"S2HTNX0H509266", 7, "X0XXXX0X000007"},
+		{"2712646402", 5, "1000000005"},
+	}
+
+	for _, tt := range tests {
+		if got := fakeIdentifier(tt.value, tt.n); got != tt.want {
+			t.Errorf("fakeIdentifier('%s', %d) = '%s', want '%s'", tt.value, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRecorderSmartctl(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("testdata", "mdraid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	r := &Recorder{Dir: dir}
+
+	smartArgs := SmartDevice{Type: "sat", Path: "/dev/sdb"}.args()
+	smart := r.Runner("mdraid", root, FixtureRunner{Dir: "testdata/smart", Files: map[string]string{strings.Join(smartArgs, " "): "ssd.json"}})
+	files := r.Runner("mdraid", root, FileRunner{})
+
+	// smartctl runs first, manifest binary still comes from vendor
+	if _, err := smart.Run("smartctl", smartArgs...); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := files.Run(root, mdstatPath); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	m, err := r.Write()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	mv := m.Vendors["mdraid"]
+	if mv.Binary != root {
+		t.Errorf("got binary '%s', want '%s'", mv.Binary, root)
+	}
+
+	smartKey := "smartctl " + strings.Join(smartArgs, " ")
+	for _, key := range []string{smartKey, mdstatPath} {
+		if _, ok := mv.Files[key]; !ok {
+			t.Errorf("command '%s' not recorded: %v", key, mv.Files)
+		}
+	}
+
+	replay, err := NewReplayRunner(dir, "mdraid")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if data, err := replay.Run("/usr/sbin/smartctl", smartArgs...); err != nil || !strings.Contains(string(data), "smart_status") {
+		t.Errorf("got replayed smartctl output error %v", err)
+	}
+
+	if _, err := replay.Run("/", mdstatPath); err != nil {
+		t.Errorf("got replayed mdstat error %v", err)
+	}
+}
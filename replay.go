@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReplayRunner - serves tool output recorded with 'record' from 'Dir', commands are matched by exact arguments
+type ReplayRunner struct {
+	Dir    string
+	Vendor string
+	Files  map[string]string
+	Errors map[string]ManifestError
+}
+
+// NewReplayRunner - runner replaying commands of 'vendor' recorded in 'dir'
+func NewReplayRunner(dir string, vendor string) (ReplayRunner, error) {
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return ReplayRunner{}, err
+	}
+
+	mv, ok := m.Vendors[vendor]
+	if !ok {
+		return ReplayRunner{}, fmt.Errorf("vendor '%s' is not recorded in '%s'", vendor, dir)
+	}
+
+	return ReplayRunner{Dir: dir, Vendor: vendor, Files: mv.Files, Errors: mv.Errors}, nil
+}
+
+// Run - return recorded output or error of command, commands of other tools than vendor's are recorded with tool name
+func (r ReplayRunner) Run(execPath string, args ...string) ([]byte, error) {
+	key := commandLine(filepath.Base(execPath), args)
+	if _, ok := r.Files[key]; !ok {
+		if _, ok := r.Errors[key]; !ok {
+			key = strings.Join(args, " ")
+		}
+	}
+
+	if e, ok := r.Errors[key]; ok {
+		return nil, &CommandError{Code: e.Code, Command: commandLine(execPath, args), Err: errors.New(e.Message)}
+	}
+
+	if _, ok := r.Files[key]; !ok {
+		command := commandLine(execPath, args)
+		return nil, &CommandError{Code: ErrorCodeGeneric, Command: command, Err: fmt.Errorf("command '%s' of vendor '%s' is not recorded in '%s'", command, r.Vendor, r.Dir)}
+	}
+
+	logger.Debugf("command '%s' replayed from '%s'", commandLine(execPath, args), r.Dir)
+
+	if len(r.Files[key]) == 0 {
+		return []byte{}, nil
+	}
+
+	return os.ReadFile(filepath.Join(r.Dir, r.Vendor, r.Files[key]))
+}
+
+// LoadManifest - read manifest of recorded tool output in 'dir'
+func LoadManifest(dir string) (Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error reading replay manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Manifest{}, fmt.Errorf("error in replay manifest '%s': %w", filepath.Join(dir, manifestFile), err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplayRunner(t *testing.T) {
+	for _, tt := range vendorTests {
+		dir := t.TempDir()
+		r := &Recorder{Dir: dir}
+
+		want, err := CollectDump(tt.newVendor(tt.execPath, r.Runner(tt.name, tt.execPath, tt.fixtureRunner())))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+
+		if _, err := r.Write(); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+
+		replay, err := NewReplayRunner(dir, tt.name)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+
+		got, err := CollectDump(tt.newVendor(tt.execPath, replay))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: replayed dump differs from recorded:\ngot  %+v\nwant %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestReplayRunnerErrors(t *testing.T) {
+	dir := t.TempDir()
+	r := &Recorder{Dir: dir}
+
+	runner := r.Runner("hp", "ssacli", FixtureRunner{Dir: "testdata/hp", Files: map[string]string{"ctrl all show": "controllers.txt"}})
+	runner.Run("ssacli", "ctrl", "all", "show")
+	runner.Run("ssacli", "ctrl", "slot=0", "show", "status")
+
+	if _, err := r.Write(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := NewReplayRunner(dir, "megacli"); err == nil || !strings.Contains(err.Error(), "vendor 'megacli' is not recorded") {
+		t.Errorf("got error '%v' for vendor not recorded", err)
+	}
+
+	replay, err := NewReplayRunner(dir, "hp")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if data, err := replay.Run("ssacli", "ctrl", "all", "show"); err != nil || len(data) == 0 {
+		t.Errorf("recorded command not replayed: %v", err)
+	}
+
+	_, err = replay.Run("ssacli", "ctrl", "slot=0", "show", "status")
+	if err == nil || !strings.Contains(err.Error(), "no fixture for command 'ssacli ctrl slot=0 show status'") {
+		t.Errorf("got error '%v', want recorded error", err)
+	}
+
+	_, err = replay.Run("ssacli", "ctrl", "slot=1", "show", "status")
+	if err == nil || !strings.Contains(err.Error(), "command 'ssacli ctrl slot=1 show status' of vendor 'hp' is not recorded") {
+		t.Errorf("got error '%v', want not recorded error", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Runner - executes RAID tool commands and returns their output
+type Runner interface {
+	Run(execPath string, args ...string) ([]byte, error)
+}
+
+// ExecRunner - runs RAID tool on the host, 'Args' are appended to every command,
+// 'ValidExit' accepts non-zero exit statuses of tools reporting findings with them
+type ExecRunner struct {
+	Timeout   int
+	Args      []string
+	ValidExit func(int) bool
+}
+
+// Run - execute command with GetCommandOutput
+func (r ExecRunner) Run(execPath string, args ...string) ([]byte, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return getCommandOutput(timeout, r.ValidExit, execPath, r.CommandArgs(args)...)
+}
+
+// CommandArgs - arguments command is run with, configured 'Args' are appended
+func (r ExecRunner) CommandArgs(args []string) []string {
+	return append(args[:len(args):len(args)], r.Args...)
+}
+
+// FixtureRunner - serves recorded RAID tool output from files in 'Dir',
+// 'Files' maps space-joined arguments to a file name, empty name means empty output
+type FixtureRunner struct {
+	Dir   string
+	Files map[string]string
+}
+
+// Run - return contents of file recorded for command arguments
+func (r FixtureRunner) Run(execPath string, args ...string) ([]byte, error) {
+	key := strings.Join(args, " ")
+
+	file, ok := r.Files[key]
+	if !ok {
+		return nil, fmt.Errorf("no fixture for command '%s %s'", execPath, key)
+	}
+
+	if len(file) == 0 {
+		return []byte{}, nil
+	}
+
+	return os.ReadFile(filepath.Join(r.Dir, file))
+}
+
+// FileRunner - reads kernel files for vendors without RAID tool, 'execPath' is root directory
+// and arguments are path of file under it (root '/' with 'proc/mdstat' reads '/proc/mdstat')
+type FileRunner struct{}
+
+// Run - return contents of file
+func (r FileRunner) Run(root string, args ...string) ([]byte, error) {
+	path := filepath.Join(append([]string{root}, args...)...)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &CommandError{Code: ErrorCodeGeneric, Command: path, Err: fmt.Errorf("error reading file '%s': %w", path, err)}
+	}
+
+	logger.Tracef("file '%s' contents:\n%s", path, data)
+
+	return data, nil
+}
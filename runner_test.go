@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFixtureRunner(t *testing.T) {
+	r := FixtureRunner{
+		Dir: "testdata/hp",
+		Files: map[string]string{
+			"ctrl all show":         "controllers.txt",
+			"ctrl slot=0 show none": "",
+		},
+	}
+
+	data, err := r.Run("ssacli", "ctrl", "all", "show")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(data) == 0 {
+		t.Error("expected fixture contents, got empty output")
+	}
+
+	data, err = r.Run("ssacli", "ctrl", "slot=0", "show", "none")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(data) != 0 {
+		t.Errorf("expected empty output, got %q", data)
+	}
+
+	if _, err := r.Run("ssacli", "ctrl", "slot=1", "show"); err == nil {
+		t.Error("expected error for unknown command, got nil")
+	}
+}
+
+func TestVendorWithFixtureRunner(t *testing.T) {
+	r := FixtureRunner{
+		Dir:   "testdata/megacli",
+		Files: map[string]string{"-AdpGetPciInfo -aALL": "controllers.txt"},
+	}
+
+	ids, err := NewMegacliVendor("megacli", r).GetControllersIDs()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := []string{"0"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got controllers %q, want %q", ids, want)
+	}
+}
+
+func TestExecRunnerValidExit(t *testing.T) {
+	r := ExecRunner{Timeout: 1, ValidExit: smartctlValidExit}
+
+	data, err := r.Run("sh", "-c", "echo '{}'; exit 68")
+	if err != nil {
+		t.Fatalf("unexpected error for exit status with disk problem bits: %s", err)
+	}
+	if string(data) != "{}\n" {
+		t.Errorf("got output %q, want %q", data, "{}\n")
+	}
+
+	if _, err := r.Run("sh", "-c", "echo '{}'; exit 2"); err == nil {
+		t.Error("expected error for device open failure, got nil")
+	}
+
+	if _, err := (ExecRunner{Timeout: 1}).Run("sh", "-c", "echo '{}'; exit 64"); err == nil {
+		t.Error("expected error for non-zero exit status without ValidExit, got nil")
+	}
+}
@@ -2,31 +2,85 @@ package main
 
 import (
 	"fmt"
-	"os"
-	"regexp"
 	"strings"
 )
 
+// sas2ircu status codes (in brackets after status) to normalized state
+var (
+	sas2ircuVolumeStates = StateMap{
+		"OKY":  StateOK,
+		"ONL":  StateOK,
+		"INIT": StateWarning,
+		"DGD":  StateCritical,
+		"FLD":  StateCritical,
+		"MIS":  StateCritical,
+		"INA":  StateCritical,
+	}
+	sas2ircuPDStates = StateMap{
+		"OPT":  StateOK,
+		"ONL":  StateOK,
+		"HSP":  StateOK,
+		"RDY":  StateOK,
+		"AVL":  StateOK,
+		"SBY":  StateOK,
+		"RBLD": StateWarning,
+		"OSY":  StateWarning,
+		"DGD":  StateCritical,
+		"FLD":  StateCritical,
+		"MIS":  StateCritical,
+	}
+)
+
+// first lines of physical drive sections in display output
+var (
+	sas2ircuDeviceMarkers = []string{"Device is a Hard disk"}
+	// sas3ircu reports SSDs in separate sections
+	sas3ircuDeviceMarkers = []string{"Device is a Hard disk", "Device is a SSD"}
+)
+
 type SAS2IrcuVendor struct {
-	execPath string
+	execPath      string
+	runner        Runner
+	deviceMarkers []string
+}
+
+// physicalDrives - physical drive sections of display output, hard disks first
+func (v SAS2IrcuVendor) physicalDrives(inputData []byte) (data []string) {
+	for _, m := range v.deviceMarkers {
+		data = append(data, GetArraySliceByte(inputData, m, "Drive Type")...)
+	}
+
+	return
 }
 
 // GetControllersIDs - get number of controllers in the system
-func (v SAS2IrcuVendor) GetControllersIDs() []string {
-	inputData := GetCommandOutput(v.execPath, "list")
-	return GetRegexpAllSubmatch(inputData, "\\s+(\\d+)\\s+.*")
+func (v SAS2IrcuVendor) GetControllersIDs() ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "\\s+(\\d+)\\s+.*"), nil
 }
 
 // GetLogicalDrivesIDs - get number of logical drives for controller with ID 'controllerID'
-func (v SAS2IrcuVendor) GetLogicalDrivesIDs(controllerID string) []string {
-	inputData := GetCommandOutput(v.execPath, controllerID, "display")
-	return GetRegexpAllSubmatch(inputData, "IR volume (\\d+)")
+func (v SAS2IrcuVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, controllerID, "display")
+	if err != nil {
+		return nil, err
+	}
+
+	return GetRegexpAllSubmatch(inputData, "IR volume (\\d+)"), nil
 }
 
 // GetPhysicalDrivesIDs - get number of physical drives for controller with ID 'controllerID'
-func (v SAS2IrcuVendor) GetPhysicalDrivesIDs(controllerID string) []string {
-	inputData := GetCommandOutput(v.execPath, controllerID, "display")
-	sliceArr := GetArraySliceByte(inputData, "Device is a Hard disk", "Drive Type")
+func (v SAS2IrcuVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	inputData, err := v.runner.Run(v.execPath, controllerID, "display")
+	if err != nil {
+		return nil, err
+	}
+
+	sliceArr := v.physicalDrives(inputData)
 	data := []string{}
 
 	if len(sliceArr) > 0 {
@@ -40,34 +94,33 @@ func (v SAS2IrcuVendor) GetPhysicalDrivesIDs(controllerID string) []string {
 		}
 	}
 
-	return data
+	return data, nil
 }
 
 // GetControllerStatus - get controller status
-func (v SAS2IrcuVendor) GetControllerStatus(controllerID string, indent int) []byte {
-	type ReturnData struct {
-		Status string `json:"status"`
-		Model  string `json:"model"`
-		// Temperature string `json:"temperature"`
+func (v SAS2IrcuVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	inputData, err := v.runner.Run(v.execPath, controllerID, "display")
+	if err != nil {
+		return Controller{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, controllerID, "display")
 	model := GetRegexpSubmatch(inputData, "Controller type *: (.*)")
-
-	result := regexp.MustCompile("Status of volume\\s+: .*\\((.*)\\)").FindAllStringSubmatch(string(inputData), -1)
-
-	if os.Getenv("RAIDSTAT_DEBUG") == "y" {
-		fmt.Printf("Regexp is '%s'\n", "Status of volume\\s+: .*\\((.*)\\)")
-		fmt.Printf("Result is '%s'\n", result)
+	if len(model) == 0 {
+		return Controller{}, NewParseError("controller type", v.execPath, controllerID, "display")
 	}
 
+	result := FindRegexpSubmatches(inputData, "Status of volume\\s+: .*\\((.*)\\)", -1)
+
 	healthStatuses := []string{}
+	state := StateOK
 
 	if len(result) > 0 {
 		for _, v := range result {
 			if v[1] != "OKY" {
 				healthStatuses = append(healthStatuses, fmt.Sprintf("%s", v))
 			}
+
+			state = state.Worse(sas2ircuVolumeStates.Get(v[1]))
 		}
 	}
 
@@ -78,55 +131,60 @@ func (v SAS2IrcuVendor) GetControllerStatus(controllerID string, indent int) []b
 		status = strings.Join(healthStatuses, ", ")
 	}
 
-	data := ReturnData{
+	data := Controller{
 		Status: TrimSpacesLeftAndRight(status),
+		Health: NewHealth(state),
 		Model:  TrimSpacesLeftAndRight(model),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
 // GetLDStatus - get logical drive status
-func (v SAS2IrcuVendor) GetLDStatus(controllerID string, deviceID string, indent int) []byte {
-	type ReturnData struct {
-		Status string `json:"status"`
-		Size   string `json:"size"`
+func (v SAS2IrcuVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	inputData, err := v.runner.Run(v.execPath, controllerID, "display")
+	if err != nil {
+		return LogicalDrive{}, err
 	}
 
-	inputData := GetCommandOutput(v.execPath, controllerID, "display")
 	sliceData := GetSliceByte(inputData, "IR volume "+deviceID, "Physical")
+	if len(sliceData) == 0 {
+		return LogicalDrive{}, NewUnknownDeviceError("logical drive '%s' not found on controller '%s'", deviceID, controllerID)
+	}
 
 	status := GetRegexpSubmatch(sliceData, "Status of volume *: (.*)")
-	size := GetRegexpSubmatch(sliceData, "Size \\(in MB\\) *: (.*)")
+	if len(status) == 0 {
+		return LogicalDrive{}, NewParseError("logical drive status", v.execPath, controllerID, "display")
+	}
+	size := GetRegexpSubmatch(sliceData, "Size \\(in MB\\) *: (\\d+)")
+	health := NewHealth(sas2ircuVolumeStates.Get(GetRegexpSubmatch([]byte(status), "\\((.*)\\)")))
 
 	if status == "Okay (OKY)" {
 		status = "OK"
 	}
 
-	data := ReturnData{
+	data := LogicalDrive{
 		Status: TrimSpacesLeftAndRight(status),
-		Size:   TrimSpacesLeftAndRight(size),
+		Health: health,
+		Size:   sas2ircuSize(size),
 	}
 
-	return append(MarshallJSON(data, indent), "\n"...)
+	return data, nil
 }
 
 // GetPDStatus - get physical drive status
-func (v SAS2IrcuVendor) GetPDStatus(controllerID string, deviceID string, indent int) []byte {
-	type ReturnData struct {
-		Status    string `json:"status"`
-		Model     string `json:"model"`
-		TotalSize string `json:"totalsize"`
-	}
-
+func (v SAS2IrcuVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
 	deviceData := strings.Split(deviceID, ":")
 	if len(deviceData) < 2 {
-		fmt.Printf("Error - wrong device id '%s'.\n", deviceID)
-		os.Exit(1)
+		return PhysicalDrive{}, NewUnknownDeviceError("wrong device id '%s'", deviceID)
 	}
 
-	inputData := GetCommandOutput(v.execPath, controllerID, "display")
-	sliceArr := GetArraySliceByte(inputData, "Device is a Hard disk", "Drive Type")
+	inputData, err := v.runner.Run(v.execPath, controllerID, "display")
+	if err != nil {
+		return PhysicalDrive{}, err
+	}
+
+	sliceArr := v.physicalDrives(inputData)
 
 	if len(sliceArr) > 0 {
 		for _, v := range sliceArr {
@@ -137,23 +195,25 @@ func (v SAS2IrcuVendor) GetPDStatus(controllerID string, deviceID string, indent
 				status := GetRegexpSubmatch([]byte(v), "[\\s]{2}State *: (.*)")
 				model := GetRegexpSubmatch([]byte(v), "Model Number *: (.*)")
 				totalSize := GetRegexpSubmatch([]byte(v), "Size \\(in MB\\)/\\(in sectors\\) *: (\\d+)/\\d+")
+				health := NewHealth(sas2ircuPDStates.Get(GetRegexpSubmatch([]byte(status), "\\((.*)\\)")))
 
 				if status == "Optimal (OPT)" {
 					status = "OK"
 				}
 
-				data := ReturnData{
+				data := PhysicalDrive{
 					Status:    TrimSpacesLeftAndRight(status),
+					Health:    health,
 					Model:     TrimSpacesLeftAndRight(model),
-					TotalSize: TrimSpacesLeftAndRight(totalSize),
+					TotalSize: sas2ircuSize(totalSize),
 				}
 
-				return append(MarshallJSON(data, indent), "\n"...)
+				return data, nil
 			}
 		}
 	}
 
-	return []byte("")
+	return PhysicalDrive{}, NewUnknownDeviceError("physical drive '%s' not found on controller '%s'", deviceID, controllerID)
 }
 
 func GetSliceByte(buf []byte, start string, end string) []byte {
@@ -214,7 +274,24 @@ func GetArraySliceByte(buf []byte, start string, end string) (data []string) {
 	return
 }
 
-func NewSAS2IrcuVendor(execPath string) Vendor {
-	v := SAS2IrcuVendor{execPath: execPath}
+// NewSAS2IrcuVendor - SAS2 HBAs in IR mode, it has no SmartMapper: members of IR volumes are hidden from the OS
+// and smartctl has no device type addressing them through the HBA, so their status has no SMART data
+func NewSAS2IrcuVendor(execPath string, runner Runner) Vendor {
+	v := SAS2IrcuVendor{execPath: execPath, runner: runner, deviceMarkers: sas2ircuDeviceMarkers}
+	return v
+}
+
+// NewSAS3IrcuVendor - SAS3 HBAs in IR mode, sas3ircu output differs from sas2ircu only in SSD sections
+func NewSAS3IrcuVendor(execPath string, runner Runner) Vendor {
+	v := SAS2IrcuVendor{execPath: execPath, runner: runner, deviceMarkers: sas3ircuDeviceMarkers}
 	return v
 }
+
+// sas2ircuSize - size with unit, tool prints plain number of megabytes
+func sas2ircuSize(size string) string {
+	if len(size) == 0 {
+		return ""
+	}
+
+	return size + " MB"
+}
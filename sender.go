@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTrapperPort = "10051"
+	senderTimeout      = 30 * time.Second
+	// zabbixHeader - protocol signature and version of every Zabbix message, followed by little endian data length
+	zabbixHeader = "ZBXD\x01"
+	// zabbixMaxResponse - trapper responses are short, longer length means peer doesn't speak Zabbix protocol
+	zabbixMaxResponse = 1 << 20
+)
+
+// SenderItem - value of trapper item 'Key' of host 'Host'
+type SenderItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// SenderResult - processed values reported by trapper in response 'info'
+type SenderResult struct {
+	Processed int     `json:"processed"`
+	Failed    int     `json:"failed"`
+	Total     int     `json:"total"`
+	Seconds   float64 `json:"seconds"`
+}
+
+// senderInfo - response info like 'processed: 3; failed: 0; total: 3; seconds spent: 0.000055'
+var senderInfo = regexp.MustCompile(`processed:\s*(\d+);\s*failed:\s*(\d+);\s*total:\s*(\d+);\s*seconds spent:\s*([\d.]+)`)
+
+// zabbixKeyParam - item key parameter, quoted when it contains characters splitting parameters
+func zabbixKeyParam(s string) string {
+	if !strings.ContainsAny(s, ",\"[] ") {
+		return s
+	}
+
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// zabbixKey - item key 'name' with parameters, same as keys of userparameter_raidstat.conf, 'vendor' is inserted as is
+// like {$RAID_VENDOR} is expanded by Zabbix, device ids are quoted like LLD macros
+func zabbixKey(name string, vendor string, params ...string) string {
+	quoted := []string{vendor}
+	for _, p := range params {
+		quoted = append(quoted, zabbixKeyParam(p))
+	}
+
+	return fmt.Sprintf("%s[%s]", name, strings.Join(quoted, ","))
+}
+
+// statusValue - status json of device or error json when status is unavailable, as agent returns it with '--error-output stdout'
+func statusValue(data interface{}, err error, vendor string) (string, error) {
+	if err != nil {
+		data = NewErrorReport(err, vendor)
+	}
+
+	JSON, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling JSON: %w", err)
+	}
+
+	return string(JSON), nil
+}
+
+// CollectSenderItems - walk all controllers and drives once and build discovery and status values of trapper items,
+// 'vendor' is key parameter like {$RAID_VENDOR} macro, failures of single devices are sent as error json
+func CollectSenderItems(host string, vendor string, v Vendor) ([]SenderItem, error) {
+	controllersIDs, err := v.GetControllersIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		items []SenderItem
+		cts   = []map[string]string{}
+		lds   = []map[string]string{}
+		pds   = []map[string]string{}
+	)
+
+	add := func(key string, data interface{}, statusErr error) error {
+		value, err := statusValue(data, statusErr, vendor)
+		if err != nil {
+			return err
+		}
+
+		items = append(items, SenderItem{Host: host, Key: key, Value: value})
+		return nil
+	}
+
+	for _, ctID := range controllersIDs {
+		cts = append(cts, map[string]string{"{#CT_ID}": ctID})
+
+		ct, statusErr := v.GetControllerStatus(ctID)
+		if err := add(zabbixKey("raidstat.status.controller", vendor, ctID), ct, statusErr); err != nil {
+			return nil, err
+		}
+
+		if logicalDrivesIDs, err := v.GetLogicalDrivesIDs(ctID); err != nil {
+			printError(err)
+		} else {
+			for _, ldID := range logicalDrivesIDs {
+				lds = append(lds, map[string]string{"{#CT_ID}": ctID, "{#LD_ID}": ldID})
+
+				ld, statusErr := v.GetLDStatus(ctID, ldID)
+				if err := add(zabbixKey("raidstat.status.logicaldrive", vendor, ctID, ldID), ld, statusErr); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if physicalDrivesIDs, err := v.GetPhysicalDrivesIDs(ctID); err != nil {
+			printError(err)
+		} else {
+			for _, pdID := range physicalDrivesIDs {
+				pds = append(pds, map[string]string{"{#CT_ID}": ctID, "{#PD_ID}": pdID})
+
+				pd, statusErr := v.GetPDStatus(ctID, pdID)
+				if err := add(zabbixKey("raidstat.status.physicaldrive", vendor, ctID, pdID), pd, statusErr); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// Zabbix processes LLD asynchronously, values of devices discovered in this request fail
+	// until their items are created and are accepted with next send
+	discovery := []SenderItem{}
+	for _, d := range []struct {
+		key  string
+		data []map[string]string
+	}{
+		{"raidstat.discovery.controllers", cts},
+		{"raidstat.discovery.logicaldrives", lds},
+		{"raidstat.discovery.physicaldrives", pds},
+	} {
+		value, err := statusValue(map[string]interface{}{"data": d.data}, nil, vendor)
+		if err != nil {
+			return nil, err
+		}
+
+		discovery = append(discovery, SenderItem{Host: host, Key: zabbixKey(d.key, vendor), Value: value})
+	}
+
+	return append(discovery, items...), nil
+}
+
+// zabbixMessage - data with Zabbix protocol header
+func zabbixMessage(data []byte) []byte {
+	var b bytes.Buffer
+
+	b.WriteString(zabbixHeader)
+	binary.Write(&b, binary.LittleEndian, uint64(len(data)))
+	b.Write(data)
+
+	return b.Bytes()
+}
+
+// readZabbixMessage - data of Zabbix protocol message
+func readZabbixMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, len(zabbixHeader)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("error reading response header: %w", err)
+	}
+
+	if string(header[:len(zabbixHeader)]) != zabbixHeader {
+		return nil, fmt.Errorf("wrong response header %q", header[:len(zabbixHeader)])
+	}
+
+	length := binary.LittleEndian.Uint64(header[len(zabbixHeader):])
+	if length > zabbixMaxResponse {
+		return nil, fmt.Errorf("response length %d exceeds %d bytes", length, zabbixMaxResponse)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	return data, nil
+}
+
+// parseSenderResponse - result of trapper response, response other than 'success' is error
+func parseSenderResponse(data []byte) (SenderResult, error) {
+	var response struct {
+		Response string `json:"response"`
+		Info     string `json:"info"`
+	}
+
+	if err := json.Unmarshal(data, &response); err != nil {
+		return SenderResult{}, fmt.Errorf("error parsing response '%s': %w", data, err)
+	}
+
+	if response.Response != "success" {
+		return SenderResult{}, fmt.Errorf("server responded '%s': %s", response.Response, response.Info)
+	}
+
+	m := senderInfo.FindStringSubmatch(response.Info)
+	if m == nil {
+		return SenderResult{}, fmt.Errorf("wrong response info '%s'", response.Info)
+	}
+
+	var r SenderResult
+	r.Processed, _ = strconv.Atoi(m[1])
+	r.Failed, _ = strconv.Atoi(m[2])
+	r.Total, _ = strconv.Atoi(m[3])
+	r.Seconds, _ = strconv.ParseFloat(m[4], 64)
+
+	return r, nil
+}
+
+// SendItems - push values to Zabbix server or proxy trapper at 'server' ('host' or 'host:port') with sender protocol
+func SendItems(server string, items []SenderItem, timeout time.Duration) (SenderResult, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, defaultTrapperPort)
+	}
+
+	request, err := json.Marshal(struct {
+		Request string       `json:"request"`
+		Data    []SenderItem `json:"data"`
+	}{"sender data", items})
+	if err != nil {
+		return SenderResult{}, fmt.Errorf("error marshalling JSON: %w", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return SenderResult{}, fmt.Errorf("error connecting to '%s': %w", server, err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	logger.Debugf("sending %d values to '%s'", len(items), server)
+
+	if _, err := conn.Write(zabbixMessage(request)); err != nil {
+		return SenderResult{}, fmt.Errorf("error sending to '%s': %w", server, err)
+	}
+
+	data, err := readZabbixMessage(conn)
+	if err != nil {
+		return SenderResult{}, fmt.Errorf("'%s': %w", server, err)
+	}
+
+	r, err := parseSenderResponse(data)
+	if err != nil {
+		return SenderResult{}, fmt.Errorf("'%s': %w", server, err)
+	}
+
+	if r.Failed > 0 {
+		logger.Warnf("%d of %d values were not processed by '%s', new devices are accepted after discovery, otherwise check that trapper items exist on host", r.Failed, r.Total, server)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTrapper - Zabbix trapper accepting one request, it's sent to 'requests' and answered with 'response'
+func fakeTrapper(t *testing.T, response []byte) (string, <-chan []byte) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	requests := make(chan []byte, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		data, err := readZabbixMessage(conn)
+		if err != nil {
+			t.Errorf("fake trapper: %s", err)
+		}
+		requests <- data
+
+		conn.Write(response)
+	}()
+
+	return l.Addr().String(), requests
+}
+
+func TestCollectSenderItems(t *testing.T) {
+	items, err := CollectSenderItems("db01", "megacli", fixtureVendor(t, "megacli"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		t.Fatalf("error marshalling JSON: %s", err)
+	}
+	got = append(got, '\n')
+
+	compareGolden(t, filepath.Join("testdata", "golden", "sender-megacli.json"), got)
+}
+
+func TestCollectSenderItemsError(t *testing.T) {
+	if _, err := CollectSenderItems("db01", "hp", NewHPVendor("ssacli", FixtureRunner{})); err == nil {
+		t.Error("expected error when controllers are not available, got nil")
+	}
+}
+
+func TestZabbixKey(t *testing.T) {
+	tests := []struct {
+		vendor string
+		params []string
+		want   string
+	}{
+		{"megacli", nil, "raidstat.status.controller[megacli]"},
+		{"megacli", []string{"0"}, "raidstat.status.controller[megacli,0]"},
+		{"megacli,hp", []string{"hp:0"}, "raidstat.status.controller[megacli,hp,hp:0]"},
+		{"auto", []string{`a "b"`}, `raidstat.status.controller[auto,"a \"b\""]`},
+		{"auto", []string{"mpt,0"}, `raidstat.status.controller[auto,"mpt,0"]`},
+	}
+
+	for _, tt := range tests {
+		if got := zabbixKey("raidstat.status.controller", tt.vendor, tt.params...); got != tt.want {
+			t.Errorf("vendor '%s' params %q: got key '%s', want '%s'", tt.vendor, tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestSendItems(t *testing.T) {
+	addr, requests := fakeTrapper(t, zabbixMessage([]byte(`{"response":"success","info":"processed: 1; failed: 1; total: 2; seconds spent: 0.000055"}`)))
+
+	items := []SenderItem{
+		{Host: "db01", Key: "raidstat.discovery.controllers[megacli]", Value: `{"data":[{"{#CT_ID}":"0"}]}`},
+		{Host: "db01", Key: "raidstat.status.controller[megacli,0]", Value: `{"status":"OK"}`},
+	}
+
+	got, err := SendItems(addr, items, 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := (SenderResult{Processed: 1, Failed: 1, Total: 2, Seconds: 0.000055}); got != want {
+		t.Errorf("got result %+v, want %+v", got, want)
+	}
+
+	var request struct {
+		Request string       `json:"request"`
+		Data    []SenderItem `json:"data"`
+	}
+	if err := json.Unmarshal(<-requests, &request); err != nil {
+		t.Fatalf("error parsing request: %s", err)
+	}
+
+	if request.Request != "sender data" {
+		t.Errorf("got request '%s', want 'sender data'", request.Request)
+	}
+
+	if len(request.Data) != len(items) || request.Data[1] != items[1] {
+		t.Errorf("got data %+v, want %+v", request.Data, items)
+	}
+}
+
+func TestSendItemsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response []byte
+		want     string
+	}{
+		{"failed", zabbixMessage([]byte(`{"response":"failed","info":"host is not monitored"}`)), "server responded 'failed'"},
+		{"header", []byte("HTTP/1.1 400 Bad Request\r\n\r\n"), "wrong response header"},
+		{"info", zabbixMessage([]byte(`{"response":"success","info":"ok"}`)), "wrong response info"},
+		{"short", []byte("ZBXD\x01"), "error reading response header"},
+	}
+
+	for _, tt := range tests {
+		addr, _ := fakeTrapper(t, tt.response)
+
+		_, err := SendItems(addr, []SenderItem{{Host: "db01", Key: "raidstat.discovery.controllers[megacli]", Value: "{}"}}, 5*time.Second)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error '%v', want '%s'", tt.name, err, tt.want)
+		}
+	}
+}
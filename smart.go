@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultSmartBinary = "smartctl"
+
+// SmartConfig - SMART enrichment of physical drive status with smartctl
+type SmartConfig struct {
+	Enabled bool   `json:"enabled"`
+	Binary  string `json:"binary"`
+}
+
+// smartctlValidExit - smartctl exit status is bit mask, bits 0 and 1 are command line and device open failures,
+// other bits report problems found on disk and output is complete
+func smartctlValidExit(code int) bool {
+	return code&3 == 0
+}
+
+// SmartDevice - smartctl device type and path addressing physical drive ('megaraid,8' on '/dev/bus/0')
+type SmartDevice struct {
+	Type string
+	Path string
+}
+
+// args - smartctl arguments reading all SMART data of device as JSON
+func (d SmartDevice) args() []string {
+	return []string{"--json", "-a", "-d", d.Type, d.Path}
+}
+
+// SmartMapper - vendor able to address its physical drives with smartctl, devices of all drives
+// on controller are resolved at once, drives missing in result can't be addressed;
+// sas2ircu, sas3ircu and marvell don't implement it as smartctl can't reach their RAID members
+type SmartMapper interface {
+	SmartDevices(controllerID string, host ControllerHost) (map[string]SmartDevice, error)
+}
+
+// SCSIHost - SCSI host of controller, 'Generic' is SCSI generic device of its first device ('/dev/sg0')
+type SCSIHost struct {
+	Number  int
+	Driver  string
+	Generic string
+}
+
+// SCSIHosts - SCSI hosts from sysfs tree at 'Root'
+type SCSIHosts struct {
+	Root string
+}
+
+// Host - host of 'index'-th controller handled by one of 'drivers', hosts are ordered by number
+func (h SCSIHosts) Host(index int, drivers ...string) (SCSIHost, error) {
+	paths, _ := filepath.Glob(filepath.Join(h.Root, "class", "scsi_host", "host*"))
+
+	var hosts []SCSIHost
+
+	for _, p := range paths {
+		number, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(p), "host"))
+		if err != nil {
+			continue
+		}
+
+		driver := readSysfsValue(filepath.Join(p, "proc_name"))
+		for _, d := range drivers {
+			if d == driver {
+				hosts = append(hosts, SCSIHost{Number: number, Driver: driver})
+			}
+		}
+	}
+
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Number < hosts[j].Number })
+
+	if index < 0 || index >= len(hosts) {
+		return SCSIHost{}, fmt.Errorf("no SCSI host of %s controller %d in '%s'", strings.Join(drivers, "/"), index, h.Root)
+	}
+
+	host := hosts[index]
+
+	generic, _ := filepath.Glob(filepath.Join(h.Root, "class", "scsi_host", fmt.Sprintf("host%d", host.Number), "device", "target*", "*", "scsi_generic", "sg*"))
+	if len(generic) > 0 {
+		host.Generic = "/dev/" + filepath.Base(generic[0])
+	}
+
+	return host, nil
+}
+
+// ControllerHost - SCSI host of controller at 'Index' of vendor's controller list, controllers and SCSI hosts are both in PCI order
+type ControllerHost struct {
+	Hosts SCSIHosts
+	Index int
+}
+
+// Host - SCSI host of controller handled by one of 'drivers'
+func (h ControllerHost) Host(drivers ...string) (SCSIHost, error) {
+	return h.Hosts.Host(h.Index, drivers...)
+}
+
+// controllerIndex - position of controller in controller list 'ids'
+func controllerIndex(ids []string, controllerID string) (int, error) {
+	for i, id := range ids {
+		if id == controllerID {
+			return i, nil
+		}
+	}
+
+	return 0, NewUnknownDeviceError("controller '%s' not found", controllerID)
+}
+
+// blockDevicePartitions - partition block device names ('sda1', 'nvme0n1p2'), first group is disk name
+var blockDevicePartitions = []*regexp.Regexp{
+	regexp.MustCompile(`^((?:sd|hd|vd)[a-z]+)\d+$`),
+	regexp.MustCompile(`^(nvme\d+n\d+)p\d+$`),
+}
+
+// blockSmartDevice - disk with block device name or /dev/disk/by-id link 'name', partitions are replaced by their disk
+func blockSmartDevice(name string) SmartDevice {
+	if i := strings.LastIndex(name, "-part"); i > 0 {
+		name = name[:i]
+	}
+
+	for _, re := range blockDevicePartitions {
+		if m := re.FindStringSubmatch(name); m != nil {
+			name = m[1]
+		}
+	}
+
+	path := name
+	if !strings.HasPrefix(name, "/") {
+		path = "/dev/" + name
+		if strings.Contains(name, "-") {
+			path = "/dev/disk/by-id/" + name
+		}
+	}
+
+	d := SmartDevice{Type: "sat", Path: path}
+	if strings.HasPrefix(filepath.Base(path), "nvme") {
+		d.Type = "nvme"
+	}
+
+	return d
+}
+
+// smartAttribute - row of ATA SMART attributes table
+type smartAttribute struct {
+	ID    int `json:"id"`
+	Value int `json:"value"`
+	Raw   struct {
+		Value int64 `json:"value"`
+	} `json:"raw"`
+}
+
+// smartctlOutput - parts of 'smartctl --json -a' output merged into physical drive status
+type smartctlOutput struct {
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	PowerOnTime *struct {
+		Hours int64 `json:"hours"`
+	} `json:"power_on_time"`
+	Temperature *struct {
+		Current int64 `json:"current"`
+	} `json:"temperature"`
+	AtaSmartAttributes struct {
+		Table []smartAttribute `json:"table"`
+	} `json:"ata_smart_attributes"`
+	ScsiGrownDefectList        *int64 `json:"scsi_grown_defect_list"`
+	ScsiPercentageUsed         *int64 `json:"scsi_percentage_used_endurance_indicator"`
+	NvmeSmartHealthInformation *struct {
+		PercentageUsed int64 `json:"percentage_used"`
+		MediaErrors    int64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+// ATA SMART attribute ids
+const (
+	smartReallocatedSectors = 5
+	smartPendingSectors     = 197
+	smartCRCErrors          = 199
+	// normalized value of wear attributes is remaining life in percent
+	smartWearLevelingCount     = 177
+	smartSSDLifeLeft           = 231
+	smartMediaWearoutIndicator = 233
+)
+
+// attribute - ATA SMART attribute by id
+func (o smartctlOutput) attribute(id int) (smartAttribute, bool) {
+	for _, a := range o.AtaSmartAttributes.Table {
+		if a.ID == id {
+			return a, true
+		}
+	}
+
+	return smartAttribute{}, false
+}
+
+// merge - add SMART data to drive status, values reported by vendor tool are kept,
+// failed SMART overall health makes drive state warning
+func (o smartctlOutput) merge(data PhysicalDrive) PhysicalDrive {
+	setValue := func(field *string, value int64) {
+		if len(*field) == 0 {
+			*field = strconv.FormatInt(value, 10)
+		}
+	}
+
+	if o.SmartStatus != nil {
+		if !o.SmartStatus.Passed {
+			data.Smart = "FAILED"
+			data.Health = NewHealth(data.StateCode.Worse(StateWarning))
+		} else if len(data.Smart) == 0 {
+			data.Smart = "OK"
+		}
+	}
+
+	if o.PowerOnTime != nil {
+		setValue(&data.PowerOnHours, o.PowerOnTime.Hours)
+	}
+
+	if o.Temperature != nil {
+		setValue(&data.CurrentTemperature, o.Temperature.Current)
+	}
+
+	if a, ok := o.attribute(smartReallocatedSectors); ok {
+		setValue(&data.ReallocatedSectors, a.Raw.Value)
+	} else if o.ScsiGrownDefectList != nil {
+		setValue(&data.ReallocatedSectors, *o.ScsiGrownDefectList)
+	}
+
+	if a, ok := o.attribute(smartPendingSectors); ok {
+		setValue(&data.PendingSectors, a.Raw.Value)
+	}
+
+	if a, ok := o.attribute(smartCRCErrors); ok {
+		setValue(&data.CRCErrors, a.Raw.Value)
+	}
+
+	for _, id := range []int{smartWearLevelingCount, smartSSDLifeLeft, smartMediaWearoutIndicator} {
+		if a, ok := o.attribute(id); ok {
+			setValue(&data.WearLevel, int64(100-a.Value))
+			break
+		}
+	}
+
+	if o.ScsiPercentageUsed != nil {
+		setValue(&data.WearLevel, *o.ScsiPercentageUsed)
+	}
+
+	if nvme := o.NvmeSmartHealthInformation; nvme != nil {
+		setValue(&data.WearLevel, nvme.PercentageUsed)
+		setValue(&data.MediaErrors, nvme.MediaErrors)
+	}
+
+	return data
+}
+
+// smartDevices - smartctl devices of controllers resolved once, they are kept until controllers are listed again
+type smartDevices struct {
+	mu          sync.Mutex
+	controllers []string
+	devices     map[string]map[string]SmartDevice
+	errs        map[string]error
+}
+
+// reset - forget resolved devices, controllers listed by vendor are 'controllers'
+func (d *smartDevices) reset(controllers []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.controllers = controllers
+	d.devices = map[string]map[string]SmartDevice{}
+	d.errs = map[string]error{}
+}
+
+// smartVendor - adds smartctl data to physical drive status of vendor implementing SmartMapper
+type smartVendor struct {
+	Vendor
+	mapper   SmartMapper
+	execPath string
+	runner   Runner
+	hosts    SCSIHosts
+	devices  *smartDevices
+}
+
+// GetControllersIDs - list controllers, devices resolved before are dropped as drives may have changed since
+func (v smartVendor) GetControllersIDs() ([]string, error) {
+	ids, err := v.Vendor.GetControllersIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	v.devices.reset(ids)
+
+	return ids, nil
+}
+
+// smartDevice - smartctl device of drive, devices of its controller are resolved on first call
+func (v smartVendor) smartDevice(controllerID string, deviceID string) (SmartDevice, error) {
+	d := v.devices
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.devices == nil {
+		d.devices = map[string]map[string]SmartDevice{}
+		d.errs = map[string]error{}
+	}
+
+	devices, ok := d.devices[controllerID]
+	err := d.errs[controllerID]
+
+	if !ok && err == nil {
+		devices, err = v.resolve(controllerID)
+		d.devices[controllerID] = devices
+		d.errs[controllerID] = err
+	}
+
+	if err != nil {
+		return SmartDevice{}, err
+	}
+
+	device, ok := devices[deviceID]
+	if !ok {
+		return SmartDevice{}, NewUnknownDeviceError("no smartctl device of drive '%s' on controller '%s'", deviceID, controllerID)
+	}
+
+	return device, nil
+}
+
+// resolve - smartctl devices of drives on controller, controllers are listed when they weren't yet, called with lock held
+func (v smartVendor) resolve(controllerID string) (map[string]SmartDevice, error) {
+	if v.devices.controllers == nil {
+		ids, err := v.Vendor.GetControllersIDs()
+		if err != nil {
+			return nil, err
+		}
+
+		v.devices.controllers = ids
+	}
+
+	index, err := controllerIndex(v.devices.controllers, controllerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.mapper.SmartDevices(controllerID, ControllerHost{Hosts: v.hosts, Index: index})
+}
+
+// GetPDStatus - get physical drive status with SMART data, status is returned as is when smartctl fails
+func (v smartVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	data, err := v.Vendor.GetPDStatus(controllerID, deviceID)
+	if err != nil {
+		return data, err
+	}
+
+	device, err := v.smartDevice(controllerID, deviceID)
+	if err != nil {
+		logger.Warnf("SMART data of drive '%s' on controller '%s' skipped: %s", deviceID, controllerID, err)
+		return data, nil
+	}
+
+	args := device.args()
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		logger.Warnf("SMART data of drive '%s' on controller '%s' skipped: %s", deviceID, controllerID, err)
+		return data, nil
+	}
+
+	var output smartctlOutput
+	if err := json.Unmarshal(inputData, &output); err != nil {
+		logger.Warnf("SMART data of drive '%s' on controller '%s' skipped: %s", deviceID, controllerID, NewParseError("SMART data", v.execPath, args...))
+		return data, nil
+	}
+
+	return output.merge(data), nil
+}
+
+// newSmartVendor - wrap vendor with SMART enrichment when it's enabled and vendor can address its drives
+func newSmartVendor(v Vendor, c Config, runner Runner) Vendor {
+	mapper, ok := v.(SmartMapper)
+	if !c.Smart.Enabled || !ok {
+		return v
+	}
+
+	binary := c.Smart.Binary
+	if len(binary) == 0 {
+		binary = defaultSmartBinary
+	}
+
+	return smartVendor{Vendor: v, mapper: mapper, execPath: binary, runner: runner, hosts: SCSIHosts{Root: c.SysfsRoot}, devices: &smartDevices{}}
+}
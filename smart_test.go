@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeSCSIHost - create fake SCSI host in sysfs tree at 'root', with SCSI generic device when 'generic' is set
+func makeSCSIHost(t *testing.T, root string, number int, driver string, generic string) {
+	t.Helper()
+
+	hostPath := filepath.Join(root, "class", "scsi_host", fmt.Sprintf("host%d", number))
+	if err := os.MkdirAll(hostPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(hostPath, "proc_name"), []byte(driver+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(generic) != 0 {
+		genericPath := filepath.Join(hostPath, "device", fmt.Sprintf("target%d:0:0", number), fmt.Sprintf("%d:0:0:0", number), "scsi_generic", generic)
+		if err := os.MkdirAll(genericPath, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// smartTestHosts - sysfs tree with SCSI hosts of every controller type
+func smartTestHosts(t *testing.T) SCSIHosts {
+	root := t.TempDir()
+
+	makeSCSIHost(t, root, 0, "ahci", "")
+	makeSCSIHost(t, root, 1, "megaraid_sas", "sg1")
+	makeSCSIHost(t, root, 2, "hpsa", "sg2")
+	makeSCSIHost(t, root, 3, "aacraid", "sg3")
+	makeSCSIHost(t, root, 4, "3w-9xxx", "")
+	makeSCSIHost(t, root, 10, "megaraid_sas", "sg10")
+
+	return SCSIHosts{Root: root}
+}
+
+// findVendorTest - vendor fixtures by vendor name
+func findVendorTest(t *testing.T, name string) vendorTest {
+	t.Helper()
+
+	for _, tt := range vendorTests {
+		if tt.name == name {
+			return tt
+		}
+	}
+
+	t.Fatalf("no vendor test '%s'", name)
+	return vendorTest{}
+}
+
+// pdFields - physical drive status as json field map
+func pdFields(t *testing.T, pd PhysicalDrive) map[string]interface{} {
+	t.Helper()
+
+	data, err := json.Marshal(pd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	return fields
+}
+
+var smartTests = []struct {
+	vendor       string
+	controllerID string
+	deviceID     string
+	want         SmartDevice
+	fixture      string
+	fields       map[string]interface{}
+}{
+	{"megacli", "0", "252:0", SmartDevice{Type: "megaraid,8", Path: "/dev/bus/1"}, "ata.json",
+		map[string]interface{}{"smart": "OK", "reallocatedsectors": "8", "pendingsectors": "2", "crcerrors": "0", "poweronhours": "31245"}},
+	{"storcli", "0", "252:4", SmartDevice{Type: "megaraid,12", Path: "/dev/bus/1"}, "ata.json",
+		map[string]interface{}{"reallocatedsectors": "8", "pendingsectors": "2", "poweronhours": "31245"}},
+	{"perccli", "0", "32:5", SmartDevice{Type: "megaraid,5", Path: "/dev/bus/1"}, "ata.json",
+		map[string]interface{}{"reallocatedsectors": "8", "crcerrors": "0"}},
+	{"hp", "0", "1I:1:1", SmartDevice{Type: "cciss,0", Path: "/dev/sg2"}, "sas.json",
+		map[string]interface{}{"smart": "FAILED", "reallocatedsectors": "1742", "poweronhours": "48211", "state": "warning"}},
+	{"adaptec", "1", "0,0", SmartDevice{Type: "aacraid,3,0,0", Path: "/dev/sg3"}, "sas.json",
+		map[string]interface{}{"smart": "FAILED", "reallocatedsectors": "1742"}},
+	{"3ware", "0", "2", SmartDevice{Type: "3ware,2", Path: "/dev/twa0"}, "ata.json",
+		map[string]interface{}{"pendingsectors": "2", "crcerrors": "0"}},
+	{"mdraid", "0", "sdb1", SmartDevice{Type: "sat", Path: "/dev/sdb"}, "ssd.json",
+		map[string]interface{}{"smart": "OK", "wearlevel": "8", "crcerrors": "3", "reallocatedsectors": "0", "poweronhours": "14408", "currenttemperature": "29"}},
+	{"zfs", "tank", "wwn-0x5000c500a1b2c302", SmartDevice{Type: "sat", Path: "/dev/disk/by-id/wwn-0x5000c500a1b2c302"}, "ssd.json",
+		map[string]interface{}{"wearlevel": "8", "crcerrors": "3"}},
+}
+
+func TestSmartDevice(t *testing.T) {
+	hosts := smartTestHosts(t)
+
+	for _, tt := range smartTests {
+		v := findVendorTest(t, tt.vendor).vendor()
+
+		mapper, ok := v.(SmartMapper)
+		if !ok {
+			t.Fatalf("%s: vendor doesn't implement SmartMapper", tt.vendor)
+		}
+
+		ids, err := v.GetControllersIDs()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.vendor, err)
+		}
+
+		index, err := controllerIndex(ids, tt.controllerID)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.vendor, err)
+		}
+
+		devices, err := mapper.SmartDevices(tt.controllerID, ControllerHost{Hosts: hosts, Index: index})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.vendor, err)
+		}
+
+		if got := devices[tt.deviceID]; got != tt.want {
+			t.Errorf("%s: drive '%s' got smartctl device %+v, want %+v", tt.vendor, tt.deviceID, got, tt.want)
+		}
+	}
+}
+
+func TestAdaptecSmartDevices(t *testing.T) {
+	hosts := smartTestHosts(t)
+
+	data, err := os.ReadFile(filepath.Join("testdata", "adaptec", "physicaldrives.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// drive on second channel, its channel is not the LUN
+	dir := t.TempDir()
+	data = []byte(strings.Replace(string(data), "0,7(7:0)", "1,7(7:0)", 1))
+	if err := os.WriteFile(filepath.Join(dir, "physicaldrives.txt"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	v := NewAdaptecVendor("arcconf", FixtureRunner{Dir: dir, Files: map[string]string{"getconfig 1 pd": "physicaldrives.txt"}}).(AdaptecVendor)
+
+	devices, err := v.SmartDevices("1", ControllerHost{Hosts: hosts})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for id, want := range map[string]string{"0,5": "aacraid,3,0,5", "1,7": "aacraid,3,0,7"} {
+		if got := devices[id]; got.Type != want || got.Path != "/dev/sg3" {
+			t.Errorf("drive '%s' got smartctl device %+v, want '-d %s /dev/sg3'", id, got, want)
+		}
+	}
+}
+
+func TestSmartVendor(t *testing.T) {
+	hosts := smartTestHosts(t)
+
+	c := defaultConfig()
+	c.Smart.Enabled = true
+	c.SysfsRoot = hosts.Root
+
+	for _, tt := range smartTests {
+		vt := findVendorTest(t, tt.vendor)
+
+		runner := FixtureRunner{Dir: "testdata/smart", Files: map[string]string{strings.Join(tt.want.args(), " "): tt.fixture}}
+		v := newSmartVendor(vt.vendor(), c, runner)
+
+		plain, err := vt.vendor().GetPDStatus(tt.controllerID, tt.deviceID)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.vendor, err)
+		}
+
+		got, err := v.GetPDStatus(tt.controllerID, tt.deviceID)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.vendor, err)
+		}
+
+		if got.Status != plain.Status || got.Model != plain.Model {
+			t.Errorf("%s: vendor status changed by SMART data: got %+v, want %+v", tt.vendor, got, plain)
+		}
+
+		fields := pdFields(t, got)
+		for k, want := range tt.fields {
+			if fields[k] != want {
+				t.Errorf("%s: drive '%s' got %s %v, want %v", tt.vendor, tt.deviceID, k, fields[k], want)
+			}
+		}
+	}
+}
+
+// commandCountingRunner - counts runs of every command of wrapped runner
+type commandCountingRunner struct {
+	Runner
+	calls map[string]int
+}
+
+func (r commandCountingRunner) Run(execPath string, args ...string) ([]byte, error) {
+	r.calls[strings.Join(args, " ")]++
+	return r.Runner.Run(execPath, args...)
+}
+
+func TestSmartVendorResolvesOnce(t *testing.T) {
+	hosts := smartTestHosts(t)
+
+	c := defaultConfig()
+	c.Smart.Enabled = true
+	c.SysfsRoot = hosts.Root
+
+	vt := findVendorTest(t, "hp")
+	runner := commandCountingRunner{Runner: vt.fixtureRunner(), calls: map[string]int{}}
+	v := newSmartVendor(NewHPVendor(vt.execPath, runner), c, FixtureRunner{Dir: "testdata/smart", Files: map[string]string{"--json -a -d cciss,0 /dev/sg2": "sas.json"}})
+
+	walk := func() {
+		if _, err := v.GetControllersIDs(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if _, err := v.GetPDStatus("0", "1I:1:1"); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+	}
+
+	walk()
+
+	if got := runner.calls["ctrl all show"]; got != 1 {
+		t.Errorf("controllers listed %d times, want 1", got)
+	}
+
+	if got := runner.calls["ctrl slot=0 pd all show"]; got != 1 {
+		t.Errorf("drives of controller listed %d times for 3 drive statuses, want 1", got)
+	}
+
+	// next walk lists controllers again and resolves devices anew
+	walk()
+
+	if got := runner.calls["ctrl slot=0 pd all show"]; got != 2 {
+		t.Errorf("drives of controller listed %d times in 2 walks, want 2", got)
+	}
+}
+
+func TestSmartVendorSkipped(t *testing.T) {
+	c := defaultConfig()
+	c.Smart.Enabled = true
+	c.SysfsRoot = t.TempDir()
+
+	// missing device has no disk, megacli has no SCSI host and smartctl fails for sdb
+	tests := []struct {
+		vt           vendorTest
+		controllerID string
+		deviceID     string
+	}{
+		{findVendorTest(t, "zfs"), "tank", "9876543210987654321"},
+		{findVendorTest(t, "megacli"), "0", "252:0"},
+		{findVendorTest(t, "mdraid"), "0", "sdb1"},
+	}
+
+	for _, tt := range tests {
+		want, err := tt.vt.vendor().GetPDStatus(tt.controllerID, tt.deviceID)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.vt.name, err)
+		}
+
+		got, err := newSmartVendor(tt.vt.vendor(), c, FixtureRunner{}).GetPDStatus(tt.controllerID, tt.deviceID)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.vt.name, err)
+		}
+
+		if got != want {
+			t.Errorf("%s: drive '%s' got %+v without SMART data, want %+v", tt.vt.name, tt.deviceID, got, want)
+		}
+	}
+}
+
+func TestBlockSmartDevice(t *testing.T) {
+	tests := []struct {
+		name string
+		want SmartDevice
+	}{
+		{"sda", SmartDevice{Type: "sat", Path: "/dev/sda"}},
+		{"sdab12", SmartDevice{Type: "sat", Path: "/dev/sdab"}},
+		{"nvme0n1p2", SmartDevice{Type: "nvme", Path: "/dev/nvme0n1"}},
+		{"nvme1n1", SmartDevice{Type: "nvme", Path: "/dev/nvme1n1"}},
+		{"ata-ST4000NM0035-1V4107_ZC1A2B3C-part1", SmartDevice{Type: "sat", Path: "/dev/disk/by-id/ata-ST4000NM0035-1V4107_ZC1A2B3C"}},
+		{"nvme-SAMSUNG_MZQL23T8HCLS-00A07_S64HNE0R501234", SmartDevice{Type: "nvme", Path: "/dev/disk/by-id/nvme-SAMSUNG_MZQL23T8HCLS-00A07_S64HNE0R501234"}},
+		{"/dev/disk/by-vdev/e1s3", SmartDevice{Type: "sat", Path: "/dev/disk/by-vdev/e1s3"}},
+	}
+
+	for _, tt := range tests {
+		if got := blockSmartDevice(tt.name); got != tt.want {
+			t.Errorf("device '%s': got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSmartctlOutputMerge(t *testing.T) {
+	data, err := os.ReadFile("testdata/smart/nvme.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output smartctlOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatal(err)
+	}
+
+	got := output.merge(PhysicalDrive{Status: "OK", Health: NewHealth(StateOK), CurrentTemperature: "40"})
+	want := PhysicalDrive{Status: "OK", Health: NewHealth(StateOK), CurrentTemperature: "40", Smart: "OK", PowerOnHours: "16032", WearLevel: "3", MediaErrors: "0"}
+
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import "strings"
+
+// State - vendor independent health state, value is reported as 'state_code'
+type State int
+
+const (
+	StateOK State = iota
+	StateWarning
+	StateCritical
+	StateUnknown
+)
+
+var stateNames = map[State]string{
+	StateOK:       "ok",
+	StateWarning:  "warning",
+	StateCritical: "critical",
+	StateUnknown:  "unknown",
+}
+
+// stateSeverity - order used to pick the worst of several states
+var stateSeverity = map[State]int{
+	StateOK:       0,
+	StateWarning:  1,
+	StateUnknown:  2,
+	StateCritical: 3,
+}
+
+func (s State) String() string {
+	return stateNames[s]
+}
+
+// allStateNames - names of all states in code order
+func allStateNames() []string {
+	var data []string
+	for s := StateOK; s <= StateUnknown; s++ {
+		data = append(data, s.String())
+	}
+
+	return data
+}
+
+// ParseState - state with name 'name', case is ignored
+func ParseState(name string) (State, bool) {
+	for s, n := range stateNames {
+		if strings.EqualFold(n, TrimSpacesLeftAndRight(name)) {
+			return s, true
+		}
+	}
+
+	return StateUnknown, false
+}
+
+// Worse - the more severe of two states
+func (s State) Worse(o State) State {
+	if stateSeverity[o] > stateSeverity[s] {
+		return o
+	}
+
+	return s
+}
+
+// Health - normalized state added to every status next to raw vendor 'status'
+type Health struct {
+	State     string `json:"state"`
+	StateCode State  `json:"state_code"`
+}
+
+// NewHealth - health for state 's'
+func NewHealth(s State) Health {
+	return Health{State: s.String(), StateCode: s}
+}
+
+// StateMap - raw vendor status to state, matched ignoring case, then by part before first comma
+// ('Online, Spun Up' matches 'online'), unmatched status is StateUnknown
+type StateMap map[string]State
+
+// Get - state for raw vendor status
+func (m StateMap) Get(status string) State {
+	status = strings.ToLower(TrimSpacesLeftAndRight(status))
+
+	for _, s := range []string{status, TrimSpacesLeftAndRight(strings.SplitN(status, ",", 2)[0])} {
+		for k, v := range m {
+			if strings.ToLower(k) == s {
+				return v
+			}
+		}
+	}
+
+	return StateUnknown
+}
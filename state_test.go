@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStateMapGet(t *testing.T) {
+	m := StateMap{
+		"Online":   StateOK,
+		"Rebuild":  StateWarning,
+		"Failed":   StateCritical,
+		"Hotspare": StateOK,
+	}
+
+	tests := []struct {
+		status string
+		want   State
+	}{
+		{"Online", StateOK},
+		{" online ", StateOK},
+		{"Online, Spun Up", StateOK},
+		{"Hotspare, Spun down", StateOK},
+		{"Rebuild", StateWarning},
+		{"FAILED", StateCritical},
+		{"Unconfigured(bad)", StateUnknown},
+		{"", StateUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := m.Get(tt.status); got != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestStateWorse(t *testing.T) {
+	tests := []struct {
+		a, b State
+		want State
+	}{
+		{StateOK, StateWarning, StateWarning},
+		{StateWarning, StateOK, StateWarning},
+		{StateWarning, StateUnknown, StateUnknown},
+		{StateUnknown, StateCritical, StateCritical},
+		{StateCritical, StateWarning, StateCritical},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Worse(tt.b); got != tt.want {
+			t.Errorf("%s.Worse(%s) = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestHealthJSON(t *testing.T) {
+	data, err := json.Marshal(LogicalDrive{Status: "Degraded", Health: NewHealth(StateCritical)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"status":"Degraded","state":"critical","state_code":2}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
+
+func TestVendorStateMaps(t *testing.T) {
+	tests := []struct {
+		name   string
+		m      StateMap
+		status string
+		want   State
+	}{
+		{"adaptec controller", adaptecControllerStates, "Optimal", StateOK},
+		{"adaptec ld", adaptecLDStates, "Degraded", StateCritical},
+		{"adaptec ld", adaptecLDStates, "Rebuilding", StateWarning},
+		{"adaptec pd", adaptecPDStates, "Hot Spare", StateOK},
+		{"adaptec pd", adaptecPDStates, "Failed", StateCritical},
+		{"megacli ld", megacliLDStates, "Optimal", StateOK},
+		{"megacli ld", megacliLDStates, "Partially Degraded", StateWarning},
+		{"megacli pd", megacliPDStates, "Online, Spun Up", StateOK},
+		{"megacli pd", megacliPDStates, "Unconfigured(good), Spun Up", StateOK},
+		{"megacli pd", megacliPDStates, "Unconfigured(bad)", StateCritical},
+		{"megacli pd", megacliPDStates, "Rebuild", StateWarning},
+		{"hp controller", hpControllerStates, "OK", StateOK},
+		{"hp ld", hpLDStates, "Interim Recovery Mode", StateCritical},
+		{"hp pd", hpPDStates, "Predictive Failure", StateWarning},
+		{"marvell ld", marvellLDStates, "optimal", StateOK},
+		{"marvell ld", marvellLDStates, "degraded", StateCritical},
+		{"marvell pd", marvellPDStates, "online", StateOK},
+		{"sas2ircu volume", sas2ircuVolumeStates, "OKY", StateOK},
+		{"sas2ircu volume", sas2ircuVolumeStates, "DGD", StateCritical},
+		{"sas2ircu pd", sas2ircuPDStates, "OPT", StateOK},
+		{"sas2ircu pd", sas2ircuPDStates, "RBLD", StateWarning},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.Get(tt.status); got != tt.want {
+			t.Errorf("%s: Get(%q) = %s, want %s", tt.name, tt.status, got, tt.want)
+		}
+	}
+}
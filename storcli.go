@@ -0,0 +1,415 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// storcli status values to normalized state
+var (
+	storcliControllerStates = StateMap{
+		"Optimal":         StateOK,
+		"Needs Attention": StateWarning,
+		"Degraded":        StateCritical,
+		"Failed":          StateCritical,
+	}
+	// BBU or CacheVault state, anything else makes controller state warning
+	storcliBatteryStates = StateMap{
+		"Optimal":  StateOK,
+		"Learning": StateOK,
+	}
+	storcliLDStates = StateMap{
+		"Optl": StateOK,
+		"Pdgd": StateWarning,
+		"Rec":  StateWarning,
+		"Dgrd": StateCritical,
+		"OfLn": StateCritical,
+	}
+	storcliPDStates = StateMap{
+		"Onln":   StateOK,
+		"UGood":  StateOK,
+		"GHS":    StateOK,
+		"DHS":    StateOK,
+		"JBOD":   StateOK,
+		"Rbld":   StateWarning,
+		"Cpybck": StateWarning,
+		"UGUnsp": StateWarning,
+		"Offln":  StateCritical,
+		"Failed": StateCritical,
+		"UBad":   StateCritical,
+		"UBUnsp": StateCritical,
+		"Msng":   StateCritical,
+	}
+)
+
+// storcliNoDevices - descriptions of failed commands meaning there are no such drives
+var storcliNoDevices = []string{"no drive found", "no vds have been configured", "no vd's have been configured"}
+
+// storcliOutput - output of command with 'J' option, one entry per controller
+type storcliOutput struct {
+	Controllers []struct {
+		CommandStatus struct {
+			Controller  json.RawMessage `json:"Controller"`
+			Status      string          `json:"Status"`
+			Description string          `json:"Description"`
+		} `json:"Command Status"`
+		ResponseData json.RawMessage `json:"Response Data"`
+	} `json:"Controllers"`
+}
+
+// storcliVD - row of virtual drive table
+type storcliVD struct {
+	DGVD  string `json:"DG/VD"`
+	Type  string `json:"TYPE"`
+	State string `json:"State"`
+	Size  string `json:"Size"`
+	Name  string `json:"Name"`
+}
+
+// storcliPD - row of physical drive table
+type storcliPD struct {
+	EIDSlt string `json:"EID:Slt"`
+	DID    int    `json:"DID"`
+	State  string `json:"State"`
+	Size   string `json:"Size"`
+	Model  string `json:"Model"`
+}
+
+// storcliControllerInfo - parts of 'show all' controller output used for status
+type storcliControllerInfo struct {
+	Basics struct {
+		Model string `json:"Model"`
+	} `json:"Basics"`
+	Status struct {
+		ControllerStatus string `json:"Controller Status"`
+	} `json:"Status"`
+	HwCfg struct {
+		ROCTemperature interface{} `json:"ROC temperature(Degree Celsius)"`
+		// perccli and older storcli versions
+		ROCTemperatureCelcius interface{} `json:"ROC temperature(Degree Celcius)"`
+	} `json:"HwCfg"`
+	CachevaultInfo []struct {
+		State string `json:"State"`
+	} `json:"Cachevault_Info"`
+	BBUInfo []struct {
+		State string `json:"State"`
+	} `json:"BBU_Info"`
+}
+
+// storcliDriveState - drive state part of 'show all' drive output
+type storcliDriveState struct {
+	Temperature string `json:"Drive Temperature"`
+	Smart       string `json:"S.M.A.R.T alert flagged by drive"`
+}
+
+// storcliDriveAttributes - drive device attributes part of 'show all' drive output
+type storcliDriveAttributes struct {
+	FirmwareRevision string `json:"Firmware Revision"`
+	LinkSpeed        string `json:"Link Speed"`
+}
+
+type StorcliVendor struct {
+	execPath string
+	runner   Runner
+}
+
+// run - run command and decode its JSON output
+func (v StorcliVendor) run(args ...string) (storcliOutput, error) {
+	var data storcliOutput
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return data, err
+	}
+
+	if err := json.Unmarshal(inputData, &data); err != nil {
+		command := commandLine(v.execPath, args)
+		return data, &CommandError{Code: ErrorCodeParse, Command: command, Err: fmt.Errorf("error parsing JSON output of command '%s': %w", command, err)}
+	}
+
+	return data, nil
+}
+
+// response - run command for single controller and decode its response data into 'data'
+func (v StorcliVendor) response(data interface{}, args ...string) error {
+	output, err := v.run(args...)
+	if err != nil {
+		return err
+	}
+
+	command := commandLine(v.execPath, args)
+
+	if len(output.Controllers) == 0 {
+		return NewParseError("controller", v.execPath, args...)
+	}
+
+	c := output.Controllers[0]
+	if c.CommandStatus.Status != "Success" {
+		code := ErrorCodeGeneric
+		for _, d := range storcliNoDevices {
+			if strings.HasPrefix(strings.ToLower(c.CommandStatus.Description), d) {
+				code = ErrorCodeUnknownDevice
+			}
+		}
+
+		return &CommandError{Code: code, Command: command, Err: fmt.Errorf("command '%s' failed: %s", command, c.CommandStatus.Description)}
+	}
+
+	// commands finding nothing may have no response data
+	if len(c.ResponseData) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(c.ResponseData, data); err != nil {
+		return &CommandError{Code: ErrorCodeParse, Command: command, Err: fmt.Errorf("error parsing JSON output of command '%s': %w", command, err)}
+	}
+
+	return nil
+}
+
+// GetControllersIDs - get number of controllers in the system
+func (v StorcliVendor) GetControllersIDs() ([]string, error) {
+	output, err := v.run("/call", "show", "J")
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, c := range output.Controllers {
+		if c.CommandStatus.Status != "Success" {
+			continue
+		}
+
+		data = append(data, strings.Trim(string(c.CommandStatus.Controller), "\""))
+	}
+
+	return data, nil
+}
+
+// GetLogicalDrivesIDs - get number of logical drives for controller with ID 'controllerID'
+func (v StorcliVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	var response struct {
+		VirtualDrives []storcliVD `json:"Virtual Drives"`
+	}
+
+	if err := v.response(&response, fmt.Sprintf("/c%s/vall", controllerID), "show", "J"); err != nil && !isUnknownDevice(err) {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, vd := range response.VirtualDrives {
+		if i := strings.Index(vd.DGVD, "/"); i >= 0 {
+			data = append(data, vd.DGVD[i+1:])
+		}
+	}
+
+	return data, nil
+}
+
+// GetPhysicalDrivesIDs - get number of physical drives for controller with ID 'controllerID'
+func (v StorcliVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	var response struct {
+		DriveInformation []storcliPD `json:"Drive Information"`
+	}
+
+	if err := v.response(&response, fmt.Sprintf("/c%s/eall/sall", controllerID), "show", "J"); err != nil && !isUnknownDevice(err) {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, pd := range response.DriveInformation {
+		data = append(data, strings.ReplaceAll(pd.EIDSlt, " ", ""))
+	}
+
+	return data, nil
+}
+
+// GetControllerStatus - get controller status
+func (v StorcliVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	args := []string{fmt.Sprintf("/c%s", controllerID), "show", "all", "J"}
+
+	var response storcliControllerInfo
+	if err := v.response(&response, args...); err != nil {
+		return Controller{}, err
+	}
+
+	status := TrimSpacesLeftAndRight(response.Status.ControllerStatus)
+	if len(status) == 0 {
+		return Controller{}, NewParseError("controller status", v.execPath, args...)
+	}
+	state := storcliControllerStates.Get(status)
+
+	var batteryStatus string
+	for _, b := range append(response.CachevaultInfo, response.BBUInfo...) {
+		batteryStatus = TrimSpacesLeftAndRight(b.State)
+	}
+
+	if len(batteryStatus) > 0 && storcliBatteryStates.Get(batteryStatus) != StateOK {
+		state = state.Worse(StateWarning)
+	}
+
+	if status == "Optimal" {
+		status = "OK"
+	}
+
+	var temperature string
+	for _, t := range []interface{}{response.HwCfg.ROCTemperature, response.HwCfg.ROCTemperatureCelcius} {
+		if t != nil {
+			temperature = fmt.Sprint(t)
+		}
+	}
+
+	data := Controller{
+		Status:        status,
+		Health:        NewHealth(state),
+		Model:         TrimSpacesLeftAndRight(response.Basics.Model),
+		BatteryStatus: batteryStatus,
+		Temperature:   temperature,
+	}
+
+	return data, nil
+}
+
+// GetLDStatus - get logical drive status
+func (v StorcliVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	path := fmt.Sprintf("/c%s/v%s", controllerID, deviceID)
+	args := []string{path, "show", "all", "J"}
+
+	var response map[string]json.RawMessage
+	if err := v.response(&response, args...); err != nil {
+		return LogicalDrive{}, err
+	}
+
+	var vds []storcliVD
+	if err := json.Unmarshal(response[path], &vds); err != nil || len(vds) == 0 {
+		return LogicalDrive{}, NewParseError("logical drive status", v.execPath, args...)
+	}
+
+	vd := vds[0]
+	status := TrimSpacesLeftAndRight(vd.State)
+	health := NewHealth(storcliLDStates.Get(status))
+
+	if status == "Optl" {
+		status = "OK"
+	}
+
+	data := LogicalDrive{
+		Status:   status,
+		Health:   health,
+		Name:     TrimSpacesLeftAndRight(vd.Name),
+		Size:     TrimSpacesLeftAndRight(vd.Size),
+		RaidMode: TrimSpacesLeftAndRight(vd.Type),
+	}
+
+	return data, nil
+}
+
+// GetPDStatus - get physical drive status, 'deviceID' is 'enclosure:slot' or ':slot' for drives without enclosure
+func (v StorcliVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	ids := strings.Split(deviceID, ":")
+	if len(ids) != 2 || len(ids[1]) == 0 {
+		return PhysicalDrive{}, NewUnknownDeviceError("wrong device id '%s'", deviceID)
+	}
+
+	path := fmt.Sprintf("/c%s/e%s/s%s", controllerID, ids[0], ids[1])
+	if len(ids[0]) == 0 {
+		path = fmt.Sprintf("/c%s/s%s", controllerID, ids[1])
+	}
+	args := []string{path, "show", "all", "J"}
+
+	var response map[string]json.RawMessage
+	if err := v.response(&response, args...); err != nil {
+		return PhysicalDrive{}, err
+	}
+
+	drive := "Drive " + path
+
+	var pds []storcliPD
+	if err := json.Unmarshal(response[drive], &pds); err != nil || len(pds) == 0 {
+		return PhysicalDrive{}, NewParseError("physical drive status", v.execPath, args...)
+	}
+
+	// detailed information is optional, drive table alone is enough for status
+	var (
+		details    map[string]json.RawMessage
+		state      storcliDriveState
+		attributes storcliDriveAttributes
+	)
+	if err := json.Unmarshal(response[drive+" - Detailed Information"], &details); err == nil {
+		json.Unmarshal(details[drive+" State"], &state)
+		json.Unmarshal(details[drive+" Device attributes"], &attributes)
+	}
+
+	pd := pds[0]
+	status := TrimSpacesLeftAndRight(pd.State)
+	health := NewHealth(storcliPDStates.Get(status))
+	currentTemperature := GetRegexpSubmatch([]byte(state.Temperature), "(\\d+)C")
+	smart := TrimSpacesLeftAndRight(state.Smart)
+
+	if status == "Onln" {
+		status = "OK"
+	}
+
+	if smart == "No" {
+		smart = "OK"
+	}
+
+	data := PhysicalDrive{
+		Status:             status,
+		Health:             health,
+		Model:              TrimSpacesLeftAndRight(pd.Model),
+		FirmwareVersion:    TrimSpacesLeftAndRight(attributes.FirmwareRevision),
+		Size:               TrimSpacesLeftAndRight(pd.Size),
+		CurrentSpeed:       TrimSpacesLeftAndRight(attributes.LinkSpeed),
+		CurrentTemperature: currentTemperature,
+		Smart:              smart,
+	}
+
+	return data, nil
+}
+
+// SmartDevices - drives behind MegaRAID controller are addressed by their device id on controller's SCSI host
+func (v StorcliVendor) SmartDevices(controllerID string, host ControllerHost) (map[string]SmartDevice, error) {
+	var response struct {
+		DriveInformation []storcliPD `json:"Drive Information"`
+	}
+
+	if err := v.response(&response, fmt.Sprintf("/c%s/eall/sall", controllerID), "show", "J"); err != nil {
+		return nil, err
+	}
+
+	h, err := host.Host("megaraid_sas")
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]SmartDevice{}
+
+	for _, pd := range response.DriveInformation {
+		data[strings.ReplaceAll(pd.EIDSlt, " ", "")] = SmartDevice{Type: fmt.Sprintf("megaraid,%d", pd.DID), Path: fmt.Sprintf("/dev/bus/%d", h.Number)}
+	}
+
+	return data, nil
+}
+
+// isUnknownDevice - whether command failed because there are no such devices
+func isUnknownDevice(err error) bool {
+	var cErr *CommandError
+	return errors.As(err, &cErr) && cErr.Code == ErrorCodeUnknownDevice
+}
+
+func NewStorcliVendor(execPath string, runner Runner) Vendor {
+	v := StorcliVendor{execPath: execPath, runner: runner}
+	return v
+}
+
+// NewPerccliVendor - Dell PERC controllers, perccli is storcli built by Dell with the same commands and JSON output,
+// only controller temperature key differs ('Celcius'), it's read by storcli parser as well
+func NewPerccliVendor(execPath string, runner Runner) Vendor {
+	return NewStorcliVendor(execPath, runner)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// lastSuccessSample - last success sample of vendor in textfile, groups are vendor and value
+var lastSuccessSample = regexp.MustCompile(`^raid_collector_last_success_timestamp_seconds\{vendor="([^"]*)"\} (\S+)$`)
+
+// previousLastSuccess - last success timestamps of vendors in textfile written before, missing file has none
+func previousLastSuccess(path string) map[string]float64 {
+	data := map[string]float64{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return data
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := lastSuccessSample.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		if value, err := strconv.ParseFloat(m[2], 64); err == nil {
+			data[m[1]] = value
+		}
+	}
+
+	return data
+}
+
+// WriteTextfile - collect metrics of vendors and atomically replace node_exporter textfile at 'path',
+// vendor failing now keeps its last success time from previous file, so stale status is detectable
+func WriteTextfile(path string, names []string, vendors map[string]Vendor, now time.Time) error {
+	previous := previousLastSuccess(path)
+
+	m := collectMetricSet(names, vendors)
+
+	for _, s := range m["raid_collector_success"] {
+		vendor := s.Labels[0].Value
+
+		if s.Value == 1 {
+			m.add("raid_collector_last_success_timestamp_seconds", float64(now.Unix()), "vendor", vendor)
+		} else if t, ok := previous[vendor]; ok {
+			m.add("raid_collector_last_success_timestamp_seconds", t, "vendor", vendor)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := WriteMetrics(&b, m.families()); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(path, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing metrics to '%s': %w", path, err)
+	}
+
+	logger.Infof("metrics written to '%s'", path)
+
+	return nil
+}
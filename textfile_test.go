@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTextfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "raid.prom")
+
+	names := []string{"nvme", "hp"}
+	vendors := map[string]Vendor{
+		"nvme": fixtureVendor(t, "nvme"),
+		"hp":   fixtureVendor(t, "hp"),
+	}
+
+	if err := WriteTextfile(path, names, vendors, time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// hp fails now and keeps time of previous success
+	vendors["hp"] = NewHPVendor("ssacli", FixtureRunner{})
+
+	if err := WriteTextfile(path, names, vendors, time.Unix(1700000600, 0)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`raid_collector_success{vendor="hp"} 0`,
+		`raid_collector_last_success_timestamp_seconds{vendor="nvme"} 1.7000006e+09`,
+		`raid_collector_last_success_timestamp_seconds{vendor="hp"} 1.7e+09`,
+		`raid_physical_drive_state{vendor="nvme",controller="nvme0",pd="nvme0n1"} 0`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("textfile doesn't contain '%s':\n%s", want, data)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("expected only textfile in directory, got %d files", len(entries))
+	}
+}
+
+func TestWriteTextfileNeverSucceeded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "raid.prom")
+
+	if err := WriteTextfile(path, []string{"hp"}, map[string]Vendor{"hp": NewHPVendor("ssacli", FixtureRunner{})}, time.Now()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(data), "raid_collector_last_success_timestamp_seconds") {
+		t.Errorf("expected no last success time for vendor never listed successfully:\n%s", data)
+	}
+}
+
+func TestWriteTextfileError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "raid.prom")
+
+	if err := WriteTextfile(path, []string{"nvme"}, map[string]Vendor{"nvme": fixtureVendor(t, "nvme")}, time.Now()); err == nil {
+		t.Error("expected error writing to missing directory, got nil")
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import "fmt"
+
+// Vendor - RAID tool parser
+type Vendor interface {
+	GetControllersIDs() ([]string, error)
+	GetLogicalDrivesIDs(string) ([]string, error)
+	GetPhysicalDrivesIDs(string) ([]string, error)
+	GetControllerStatus(string) (Controller, error)
+	GetLDStatus(string, string) (LogicalDrive, error)
+	GetPDStatus(string, string) (PhysicalDrive, error)
+}
+
+// Controller - controller status, fields not reported by vendor are omitted
+type Controller struct {
+	Status string `json:"status"`
+	Health
+	Model         string `json:"model,omitempty"`
+	ModelNumber   string `json:"modelnumber,omitempty"`
+	PartNumber    string `json:"partnumber,omitempty"`
+	BatteryStatus string `json:"batterystatus,omitempty"`
+	CacheStatus   string `json:"cachestatus,omitempty"`
+	Temperature   string `json:"temperature,omitempty"`
+	FanSpeed      string `json:"fanspeed,omitempty"`
+	Size          string `json:"size,omitempty"`
+	// used space in percent
+	Capacity string `json:"capacity,omitempty"`
+	// last or running scrub, resilver or consistency check and its progress in percent
+	Scan     string `json:"scan,omitempty"`
+	Progress string `json:"progress,omitempty"`
+}
+
+// LogicalDrive - logical drive status, fields not reported by vendor are omitted
+type LogicalDrive struct {
+	Status string `json:"status"`
+	Health
+	Name     string `json:"name,omitempty"`
+	Size     string `json:"size,omitempty"`
+	RaidMode string `json:"raidmode,omitempty"`
+	// rebuild, resync or check progress in percent
+	Progress     string `json:"progress,omitempty"`
+	FailedDrives string `json:"faileddrives,omitempty"`
+	SpareDrives  string `json:"sparedrives,omitempty"`
+	// I/O error counters
+	ReadErrors     string `json:"readerrors,omitempty"`
+	WriteErrors    string `json:"writeerrors,omitempty"`
+	ChecksumErrors string `json:"checksumerrors,omitempty"`
+}
+
+// PhysicalDrive - physical drive status, fields not reported by vendor are omitted
+type PhysicalDrive struct {
+	Status string `json:"status"`
+	Health
+	Model              string `json:"model,omitempty"`
+	FirmwareVersion    string `json:"firmwareversion,omitempty"`
+	Size               string `json:"size,omitempty"`
+	TotalSize          string `json:"totalsize,omitempty"`
+	CurrentSpeed       string `json:"currentspeed,omitempty"`
+	Temperature        string `json:"temperature,omitempty"`
+	CurrentTemperature string `json:"currenttemperature,omitempty"`
+	MaximumTemperature string `json:"maximumtemperature,omitempty"`
+	Smart              string `json:"smart,omitempty"`
+	SmartWarn          string `json:"smartwarnings,omitempty"`
+	ReallocatedSectors string `json:"reallocatedsectors,omitempty"`
+	PendingSectors     string `json:"pendingsectors,omitempty"`
+	CRCErrors          string `json:"crcerrors,omitempty"`
+	PowerOnHours       string `json:"poweronhours,omitempty"`
+	MediaErrors        string `json:"mediaerrors,omitempty"`
+	// used part of rated SSD endurance in percent
+	WearLevel string `json:"wearlevel,omitempty"`
+	// NVMe critical warning bits and available spare capacity in percent
+	CriticalWarning         string `json:"criticalwarning,omitempty"`
+	AvailableSpare          string `json:"availablespare,omitempty"`
+	AvailableSpareThreshold string `json:"availablesparethreshold,omitempty"`
+	// I/O error counters
+	ReadErrors     string `json:"readerrors,omitempty"`
+	WriteErrors    string `json:"writeerrors,omitempty"`
+	ChecksumErrors string `json:"checksumerrors,omitempty"`
+}
+
+// defaultFilesRoot - root directory kernel files of 'files' vendors are read under
+const defaultFilesRoot = "/"
+
+// vendorDef - vendor constructor and default RAID tool binary,
+// 'stateful' tools keep selection between calls so their output can't be cached by arguments,
+// 'files' vendors have no tool and read kernel files under configured root directory with FileRunner,
+// 'sizeBytes' vendors report sizes as plain bytes without unit
+type vendorDef struct {
+	binary    string
+	newVendor func(string, Runner) Vendor
+	stateful  bool
+	files     bool
+	sizeBytes bool
+}
+
+var vendorDefs = map[string]vendorDef{
+	"adaptec":  {binary: "arcconf", newVendor: NewAdaptecVendor},
+	"megacli":  {binary: "megacli", newVendor: NewMegacliVendor},
+	"hp":       {binary: "ssacli", newVendor: NewHPVendor},
+	"marvell":  {binary: "mvcli", newVendor: NewMarvellVendor, stateful: true},
+	"sas2ircu": {binary: "sas2ircu", newVendor: NewSAS2IrcuVendor},
+	"sas3ircu": {binary: "sas3ircu", newVendor: NewSAS3IrcuVendor},
+	"storcli":  {binary: "storcli64", newVendor: NewStorcliVendor},
+	"perccli":  {binary: "perccli64", newVendor: NewPerccliVendor},
+	"mdraid":   {newVendor: NewMdraidVendor, files: true},
+	"zfs":      {binary: "zpool", newVendor: NewZFSVendor, sizeBytes: true},
+	"3ware":    {binary: "tw_cli", newVendor: NewThreeWareVendor},
+	"areca":    {binary: "cli64", newVendor: NewArecaVendor},
+	"nvme":     {binary: "nvme", newVendor: NewNVMeVendor, sizeBytes: true},
+}
+
+// NewVendor - create vendor 'name' with binary, runner and status overrides from config
+func NewVendor(name string, c Config) (Vendor, error) {
+	def, ok := vendorDefs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown vendor '%s'", name)
+	}
+
+	runner := newRunner(name, c)
+	if c.Cache.TTL > 0 && !def.stateful && !def.files {
+		runner = CacheRunner{Runner: runner, Vendor: name, Dir: c.Cache.Dir, TTL: c.Cache.TTL}
+	}
+
+	smartRunner := newSmartRunner(c)
+	if c.Cache.TTL > 0 {
+		smartRunner = CacheRunner{Runner: smartRunner, Vendor: name, Dir: c.Cache.Dir, TTL: c.Cache.TTL}
+	}
+
+	return NewVendorWithRunner(name, c, runner, smartRunner)
+}
+
+// newRunner - runner executing tool of vendor 'name' or reading its files
+func newRunner(name string, c Config) Runner {
+	if vendorDefs[name].files {
+		return FileRunner{}
+	}
+
+	vc := c.Vendor(name)
+	return ExecRunner{Timeout: vc.Timeout, Args: vc.Args}
+}
+
+// newSmartRunner - runner executing smartctl, vendor tool arguments are not passed to it
+func newSmartRunner(c Config) Runner {
+	return ExecRunner{Timeout: c.Timeout, ValidExit: smartctlValidExit}
+}
+
+// NewVendorWithRunner - create vendor 'name' with binary and status overrides from config, commands are run by 'runner'
+// and smartctl commands of SMART enrichment by 'smartRunner'
+func NewVendorWithRunner(name string, c Config, runner Runner, smartRunner Runner) (Vendor, error) {
+	def, ok := vendorDefs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown vendor '%s'", name)
+	}
+
+	vc := c.Vendor(name)
+	v := newSmartVendor(def.newVendor(c.VendorExecPath(name), runner), c, smartRunner)
+
+	if len(vc.StatusMap) > 0 {
+		v = statusMapVendor{Vendor: v, config: vc}
+	}
+
+	return v, nil
+}
+
+// statusMapVendor - replaces statuses reported by vendor and their state with config overrides
+type statusMapVendor struct {
+	Vendor
+	config VendorConfig
+}
+
+// GetControllerStatus - get controller status with override applied
+func (v statusMapVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	data, err := v.Vendor.GetControllerStatus(controllerID)
+	data.Status, data.Health = v.config.MapStatus(data.Status, data.Health)
+	return data, err
+}
+
+// GetLDStatus - get logical drive status with override applied
+func (v statusMapVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	data, err := v.Vendor.GetLDStatus(controllerID, deviceID)
+	data.Status, data.Health = v.config.MapStatus(data.Status, data.Health)
+	return data, err
+}
+
+// GetPDStatus - get physical drive status with override applied
+func (v statusMapVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	data, err := v.Vendor.GetPDStatus(controllerID, deviceID)
+	data.Status, data.Health = v.config.MapStatus(data.Status, data.Health)
+	return data, err
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata/golden")
+
+// vendorTest - vendor fixtures and devices to query status for,
+// vendors reading kernel files use 'runner' with fixture tree as 'execPath' instead of 'commands'
+type vendorTest struct {
+	name      string
+	newVendor func(string, Runner) Vendor
+	execPath  string
+	fixtures  string
+	commands  map[string]string
+	runner    Runner
+	ctStatus  []string
+	ldStatus  [][2]string
+	pdStatus  [][2]string
+}
+
+// fixtureRunner - runner serving vendor fixtures
+func (tt vendorTest) fixtureRunner() Runner {
+	if tt.runner != nil {
+		return tt.runner
+	}
+
+	return FixtureRunner{Dir: tt.fixtures, Files: tt.commands}
+}
+
+// vendor - vendor running against its fixtures
+func (tt vendorTest) vendor() Vendor {
+	return tt.newVendor(tt.execPath, tt.fixtureRunner())
+}
+
+// goldenResult - everything vendor reports for fixtures, compared against golden file
+type goldenResult struct {
+	Controllers      []string                 `json:"controllers"`
+	LogicalDrives    map[string][]string      `json:"logicaldrives"`
+	PhysicalDrives   map[string][]string      `json:"physicaldrives"`
+	ControllerStatus map[string]Controller    `json:"controllerstatus"`
+	LDStatus         map[string]LogicalDrive  `json:"ldstatus"`
+	PDStatus         map[string]PhysicalDrive `json:"pdstatus"`
+}
+
+var vendorTests = []vendorTest{
+	{
+		name:      "adaptec",
+		newVendor: NewAdaptecVendor,
+		execPath:  "arcconf",
+		fixtures:  "testdata/adaptec",
+		commands: map[string]string{
+			"list":               "controllers.txt",
+			"getconfig 1 ld":     "logicaldrives.txt",
+			"getconfig 1 pd":     "physicaldrives.txt",
+			"getconfig 1 ad":     "controllerStatus.txt",
+			"getconfig 1 ld 0":   "logicaldrives.txt",
+			"getconfig 1 pd 0 0": "physicaldrive0_0.txt",
+			"getconfig 1 pd 0 1": "physicaldrive0_1.txt",
+			"getconfig 1 pd 0 2": "physicaldrive0_2.txt",
+			"getconfig 1 pd 0 3": "physicaldrive0_3.txt",
+			"getconfig 1 pd 0 4": "physicaldrive0_4.txt",
+			"getconfig 1 pd 0 5": "physicaldrive0_5.txt",
+			"getconfig 1 pd 0 6": "physicaldrive0_6.txt",
+			"getconfig 1 pd 0 7": "physicaldrive0_7.txt",
+		},
+		ctStatus: []string{"1"},
+		ldStatus: [][2]string{{"1", "0"}},
+		pdStatus: [][2]string{{"1", "0,0"}, {"1", "0,3"}},
+	},
+	{
+		name:      "megacli",
+		newVendor: NewMegacliVendor,
+		execPath:  "megacli",
+		fixtures:  "testdata/megacli",
+		commands: map[string]string{
+			"-AdpGetPciInfo -aALL":                "controllers.txt",
+			"-LdInfo -Lall -a0 -NoLog":            "logicaldrives.txt",
+			"-PDList -a0 -NoLog":                  "physicaldrives.txt",
+			"-AdpAllInfo -a0 -NoLog":              "controllerStatus.txt",
+			"-AdpBbuCmd -GetBbuStatus -a0 -NoLog": "controllerBBUStatus.txt",
+			"-LdInfo -L0 -a0 -NoLog":              "logicaldrive0Status.txt",
+			"-LdInfo -L1 -a0 -NoLog":              "logicaldrive1Status.txt",
+			"-LdInfo -L2 -a0 -NoLog":              "logicaldriveStatus.txt",
+			"-pdInfo -PhysDrv[252:0] -a0 -NoLog":  "physicaldrive252_0Status.txt",
+			"-pdInfo -PhysDrv[252:1] -a0 -NoLog":  "physicaldrive252_1Status.txt",
+			"-pdInfo -PhysDrv[252:2] -a0 -NoLog":  "physicaldrive252_2Status.txt",
+			"-pdInfo -PhysDrv[252:3] -a0 -NoLog":  "physicaldriveStatus.txt",
+			"-pdInfo -PhysDrv[252:4] -a0 -NoLog":  "physicaldrive252_4Status.txt",
+			"-pdInfo -PhysDrv[252:5] -a0 -NoLog":  "physicaldrive252_5Status.txt",
+		},
+		ctStatus: []string{"0"},
+		ldStatus: [][2]string{{"0", "0"}, {"0", "1"}, {"0", "2"}},
+		pdStatus: [][2]string{{"0", "252:0"}, {"0", "252:1"}, {"0", "252:2"}, {"0", "252:3"}, {"0", "252:4"}, {"0", "252:5"}},
+	},
+	{
+		name:      "hp",
+		newVendor: NewHPVendor,
+		execPath:  "ssacli",
+		fixtures:  "testdata/hp",
+		commands: map[string]string{
+			"ctrl all show":                     "controllers.txt",
+			"ctrl slot=0 ld all show":           "logicaldrives.txt",
+			"ctrl slot=0 pd all show":           "physicaldrives.txt",
+			"ctrl slot=0 show status":           "controllerStatus.txt",
+			"ctrl slot=0 ld 1 show detail":      "logicaldriveStatus.txt",
+			"ctrl slot=0 pd 1I:1:1 show detail": "physicaldriveStatus.txt",
+		},
+		ctStatus: []string{"0"},
+		ldStatus: [][2]string{{"0", "1"}},
+		pdStatus: [][2]string{{"0", "1I:1:1"}},
+	},
+	{
+		name:      "marvell",
+		newVendor: NewMarvellVendor,
+		execPath:  "mvcli",
+		fixtures:  "testdata/marvell",
+		commands: map[string]string{
+			"info -o hba":      "controllers.txt",
+			"info -o hba -i 0": "controllers.txt",
+			"adapter -i 0":     "",
+			"info -o ld":       "logicaldrives.txt",
+			"info -o pd":       "physicaldrives.txt",
+			"info -o ld -i 0":  "logicaldrives.txt",
+			"info -o pd -i 0":  "physicaldrives.txt",
+			"info -o pd -i 1":  "physicaldrive1.txt",
+		},
+		ctStatus: []string{"0"},
+		ldStatus: [][2]string{{"0", "0"}},
+		pdStatus: [][2]string{{"0", "0"}, {"0", "1"}},
+	},
+	{
+		name:      "sas2ircu",
+		newVendor: NewSAS2IrcuVendor,
+		execPath:  "sas2ircu",
+		fixtures:  "testdata/sas2ircu",
+		commands: map[string]string{
+			"list":      "list.txt",
+			"0 display": "display.txt",
+		},
+		ctStatus: []string{"0"},
+		ldStatus: [][2]string{{"0", "1"}, {"0", "2"}},
+		pdStatus: [][2]string{{"0", "1:0"}, {"0", "1:3"}},
+	},
+	{
+		name:      "sas3ircu",
+		newVendor: NewSAS3IrcuVendor,
+		execPath:  "sas3ircu",
+		fixtures:  "testdata/sas3ircu",
+		commands: map[string]string{
+			"list":      "list.txt",
+			"0 display": "display.txt",
+		},
+		ctStatus: []string{"0"},
+		ldStatus: [][2]string{{"0", "1"}, {"0", "2"}},
+		pdStatus: [][2]string{{"0", "2:0"}, {"0", "2:3"}},
+	},
+	{
+		name:      "storcli",
+		newVendor: NewStorcliVendor,
+		execPath:  "storcli64",
+		fixtures:  "testdata/storcli",
+		commands: map[string]string{
+			"/call show J":           "controllers.json",
+			"/c0/vall show J":        "logicaldrives.json",
+			"/c0/eall/sall show J":   "physicaldrives.json",
+			"/c1/vall show J":        "logicaldrives-empty.json",
+			"/c1/eall/sall show J":   "physicaldrives-empty.json",
+			"/c0 show all J":         "controllerStatus.json",
+			"/c1 show all J":         "controller1Status.json",
+			"/c0/v0 show all J":      "logicaldrive0Status.json",
+			"/c0/v1 show all J":      "logicaldriveStatus.json",
+			"/c0/e252/s0 show all J": "physicaldrive252_0Status.json",
+			"/c0/e252/s1 show all J": "physicaldrive252_1Status.json",
+			"/c0/e252/s2 show all J": "physicaldrive252_2Status.json",
+			"/c0/e252/s3 show all J": "physicaldrive252_3Status.json",
+			"/c0/e252/s4 show all J": "physicaldriveStatus.json",
+			"/c0/e252/s5 show all J": "physicaldrive252_5Status.json",
+		},
+		ctStatus: []string{"0"},
+		ldStatus: [][2]string{{"0", "1"}},
+		pdStatus: [][2]string{{"0", "252:4"}},
+	},
+	{
+		name:      "perccli",
+		newVendor: NewPerccliVendor,
+		execPath:  "perccli64",
+		fixtures:  "testdata/perccli",
+		commands: map[string]string{
+			"/call show J":          "controllers.json",
+			"/c0/vall show J":       "logicaldrives.json",
+			"/c0/eall/sall show J":  "physicaldrives.json",
+			"/c0 show all J":        "controllerStatus.json",
+			"/c0/v1 show all J":     "logicaldriveStatus.json",
+			"/c0/e32/s5 show all J": "physicaldriveStatus.json",
+		},
+		ctStatus: []string{"0"},
+		ldStatus: [][2]string{{"0", "1"}},
+		pdStatus: [][2]string{{"0", "32:5"}},
+	},
+	{
+		name:      "3ware",
+		newVendor: NewThreeWareVendor,
+		execPath:  "tw_cli",
+		fixtures:  "testdata/3ware",
+		commands: map[string]string{
+			"show":            "controllers.txt",
+			"/c0 show all":    "controllerStatus.txt",
+			"/c0/u0 show all": "unit0Status.txt",
+			"/c0/u1 show all": "unit1Status.txt",
+			"/c0/p2 show all": "port2Status.txt",
+			"/c0/p5 show all": "port5Status.txt",
+		},
+		ctStatus: []string{"0"},
+		ldStatus: [][2]string{{"0", "0"}, {"0", "1"}},
+		pdStatus: [][2]string{{"0", "2"}, {"0", "5"}},
+	},
+	{
+		name:      "areca",
+		newVendor: NewArecaVendor,
+		execPath:  "cli64",
+		fixtures:  "testdata/areca",
+		commands: map[string]string{
+			"main":                    "controllers.txt",
+			"ctrl=1 sys info":         "sysInfo.txt",
+			"ctrl=1 rsf info":         "rsfInfo.txt",
+			"ctrl=1 vsf info":         "vsfInfo.txt",
+			"ctrl=1 hw info":          "hwInfo.txt",
+			"ctrl=1 disk info":        "diskInfo.txt",
+			"ctrl=1 disk info drv=9":  "diskInfo9.txt",
+			"ctrl=1 disk info drv=18": "diskInfo18.txt",
+			"ctrl=1 disk info drv=19": "diskInfo19.txt",
+		},
+		ctStatus: []string{"1"},
+		ldStatus: [][2]string{{"1", "1"}, {"1", "2"}},
+		pdStatus: [][2]string{{"1", "9"}, {"1", "18"}, {"1", "19"}},
+	},
+	{
+		name:      "nvme",
+		newVendor: NewNVMeVendor,
+		execPath:  "nvme",
+		fixtures:  "testdata/nvme",
+		commands: map[string]string{
+			"list -o json":                 "list.json",
+			"smart-log /dev/nvme0 -o json": "nvme0SmartLog.json",
+			"smart-log /dev/nvme1 -o json": "nvme1SmartLog.json",
+			"smart-log /dev/nvme2 -o json": "nvme2SmartLog.json",
+		},
+		ctStatus: []string{"nvme0", "nvme1", "nvme2"},
+		pdStatus: [][2]string{{"nvme0", "nvme0n1"}, {"nvme1", "nvme1n1"}, {"nvme2", "nvme2n1"}, {"nvme2", "nvme2n2"}},
+	},
+	{
+		name:      "mdraid",
+		newVendor: NewMdraidVendor,
+		execPath:  "testdata/mdraid",
+		runner:    FileRunner{},
+		ctStatus:  []string{"0"},
+		ldStatus:  [][2]string{{"0", "md0"}, {"0", "md1"}, {"0", "md127"}},
+		pdStatus:  [][2]string{{"0", "sdb1"}, {"0", "sda2"}, {"0", "sdb2"}, {"0", "sdc2"}, {"0", "sdd2"}},
+	},
+	{
+		name:      "zfs",
+		newVendor: NewZFSVendor,
+		execPath:  "zpool",
+		fixtures:  "testdata/zfs",
+		commands: map[string]string{
+			"list -Hp -o name,size,allocated,free,capacity,health": "pools.txt",
+			"status -p rpool": "rpoolStatus.txt",
+			"status -p tank":  "tankStatus.txt",
+		},
+		ctStatus: []string{"rpool", "tank"},
+		ldStatus: [][2]string{{"rpool", "mirror-0"}, {"tank", "raidz2-0"}, {"tank", "mirror-1"}},
+		pdStatus: [][2]string{{"tank", "wwn-0x5000c500a1b2c302"}, {"tank", "9876543210987654321"}, {"tank", "wwn-0x5000c500a1b2c309"}, {"tank", "wwn-0x5000c500a1b2c305"}, {"tank", "wwn-0x5000c500a1b2c307"}},
+	},
+}
+
+func TestVendorsGolden(t *testing.T) {
+	for _, tt := range vendorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.vendor()
+
+			result, err := collectGoldenResult(v, tt)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			got, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				t.Fatalf("error marshalling JSON: %s", err)
+			}
+			got = append(got, '\n')
+
+			compareGolden(t, filepath.Join("testdata", "golden", tt.name+".json"), got)
+		})
+	}
+}
+
+// collectGoldenResult - run discovery and requested status queries against vendor
+func collectGoldenResult(v Vendor, tt vendorTest) (goldenResult, error) {
+	result := goldenResult{
+		LogicalDrives:    map[string][]string{},
+		PhysicalDrives:   map[string][]string{},
+		ControllerStatus: map[string]Controller{},
+		LDStatus:         map[string]LogicalDrive{},
+		PDStatus:         map[string]PhysicalDrive{},
+	}
+
+	controllersIDs, err := v.GetControllersIDs()
+	if err != nil {
+		return result, err
+	}
+	result.Controllers = controllersIDs
+
+	for _, ctID := range controllersIDs {
+		if result.LogicalDrives[ctID], err = v.GetLogicalDrivesIDs(ctID); err != nil {
+			return result, err
+		}
+
+		if result.PhysicalDrives[ctID], err = v.GetPhysicalDrivesIDs(ctID); err != nil {
+			return result, err
+		}
+	}
+
+	for _, ctID := range tt.ctStatus {
+		if result.ControllerStatus[ctID], err = v.GetControllerStatus(ctID); err != nil {
+			return result, err
+		}
+	}
+
+	for _, d := range tt.ldStatus {
+		if result.LDStatus[d[0]+","+d[1]], err = v.GetLDStatus(d[0], d[1]); err != nil {
+			return result, err
+		}
+	}
+
+	for _, d := range tt.pdStatus {
+		if result.PDStatus[d[0]+","+d[1]], err = v.GetPDStatus(d[0], d[1]); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// compareGolden - compare data with golden file, rewrite it when -update is set
+func compareGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading golden file (run 'go test -update' to create it): %s", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run 'go test -update' to accept):\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestZFSVdevWithoutIndex(t *testing.T) {
+	v := NewZFSVendor("zpool", FixtureRunner{Dir: "testdata/zfs", Files: map[string]string{"status -p legacy": "legacyStatus.txt"}})
+
+	ld, err := v.GetLDStatus("legacy", "mirror")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if ld.RaidMode != "mirror" {
+		t.Errorf("got raid mode '%s', want 'mirror'", ld.RaidMode)
+	}
+}
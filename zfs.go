@@ -0,0 +1,394 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// zpool states to normalized state
+var (
+	zfsPoolStates = StateMap{
+		"ONLINE":    StateOK,
+		"DEGRADED":  StateCritical,
+		"FAULTED":   StateCritical,
+		"OFFLINE":   StateCritical,
+		"UNAVAIL":   StateCritical,
+		"REMOVED":   StateCritical,
+		"SUSPENDED": StateCritical,
+	}
+	zfsDeviceStates = StateMap{
+		"ONLINE":      StateOK,
+		"AVAIL":       StateOK,
+		"INUSE":       StateOK,
+		"resilvering": StateWarning,
+		"OFFLINE":     StateWarning,
+		"DEGRADED":    StateCritical,
+		"FAULTED":     StateCritical,
+		"UNAVAIL":     StateCritical,
+		"REMOVED":     StateCritical,
+	}
+)
+
+// zfsListColumns - 'zpool list' columns, in order of zfsPool fields
+var zfsListColumns = []string{"name", "size", "allocated", "free", "capacity", "health"}
+
+// zfsPool - row of 'zpool list' output
+type zfsPool struct {
+	name     string
+	size     string
+	capacity string
+	health   string
+}
+
+// zfsDevice - row of 'zpool status' config, 'class' is section of top level vdevs ('logs', 'cache', 'spares'),
+// empty for data vdevs
+type zfsDevice struct {
+	name     string
+	state    string
+	read     string
+	write    string
+	checksum string
+	note     string
+	depth    int
+	class    string
+	leaf     bool
+}
+
+// zfsPoolStatus - parts of 'zpool status' output used for status
+type zfsPoolStatus struct {
+	scan     string
+	progress string
+	errors   string
+	devices  []zfsDevice
+}
+
+// vdevs - top level vdevs, cache devices and spares are not vdevs
+func (s zfsPoolStatus) vdevs() []zfsDevice {
+	var data []zfsDevice
+	for _, d := range s.devices {
+		if d.depth == 1 && d.class != "cache" && d.class != "spares" {
+			data = append(data, d)
+		}
+	}
+
+	return data
+}
+
+// leaves - leaf devices, top level vdev of one disk is leaf as well
+func (s zfsPoolStatus) leaves() []zfsDevice {
+	var data []zfsDevice
+	for _, d := range s.devices {
+		if d.leaf {
+			data = append(data, d)
+		}
+	}
+
+	return data
+}
+
+// children - leaf devices of top level vdev 'name'
+func (s zfsPoolStatus) children(name string) []zfsDevice {
+	var data []zfsDevice
+
+	inside := false
+	for _, d := range s.devices {
+		if d.depth <= 1 {
+			inside = d.name == name
+		}
+
+		if inside && d.leaf {
+			data = append(data, d)
+		}
+	}
+
+	return data
+}
+
+type ZFSVendor struct {
+	execPath string
+	runner   Runner
+}
+
+// pools - pools from 'zpool list'
+func (v ZFSVendor) pools() ([]zfsPool, error) {
+	inputData, err := v.runner.Run(v.execPath, "list", "-Hp", "-o", strings.Join(zfsListColumns, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	var data []zfsPool
+
+	for _, line := range strings.Split(string(inputData), "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) != len(zfsListColumns) {
+			continue
+		}
+
+		data = append(data, zfsPool{name: f[0], size: f[1], capacity: f[4], health: f[5]})
+	}
+
+	return data, nil
+}
+
+// status - parse 'zpool status' output of pool 'name'
+func (v ZFSVendor) status(name string) (zfsPoolStatus, error) {
+	args := []string{"status", "-p", name}
+
+	inputData, err := v.runner.Run(v.execPath, args...)
+	if err != nil {
+		return zfsPoolStatus{}, err
+	}
+
+	if GetRegexpSubmatch(inputData, "(?m)^\\s*pool: (.*)$") != name {
+		return zfsPoolStatus{}, NewParseError("pool", v.execPath, args...)
+	}
+
+	data := zfsPoolStatus{
+		scan:     GetRegexpSubmatch(inputData, "(?m)^\\s*scan: (.*)$"),
+		progress: GetRegexpSubmatch(inputData, "([\\d.]+)% done"),
+		errors:   GetRegexpSubmatch(inputData, "(?m)^errors: (.*)$"),
+	}
+
+	config := GetRegexpSubmatch(inputData, "(?s)\nconfig:\\s*\n(.*?)(?:\nerrors:|$)")
+	if len(config) == 0 {
+		return zfsPoolStatus{}, NewParseError("pool config", v.execPath, args...)
+	}
+
+	var class string
+
+	for _, line := range strings.Split(config, "\n") {
+		line = strings.TrimRight(strings.TrimPrefix(line, "\t"), " \t")
+		f := strings.Fields(line)
+
+		if len(f) == 0 || f[0] == "NAME" {
+			continue
+		}
+
+		d := zfsDevice{name: f[0], depth: (len(line) - len(strings.TrimLeft(line, " "))) / 2}
+
+		// section of special vdevs ('logs', 'cache', 'spares', 'special', 'dedup')
+		if d.depth == 0 && len(f) == 1 {
+			class = f[0]
+			continue
+		}
+
+		d.class = class
+		if len(f) > 1 {
+			d.state = f[1]
+		}
+
+		if len(f) > 4 {
+			d.read, d.write, d.checksum = f[2], f[3], f[4]
+			d.note = strings.Join(f[5:], " ")
+		}
+
+		if n := len(data.devices); n > 0 && data.devices[n-1].depth >= d.depth {
+			data.devices[n-1].leaf = data.devices[n-1].depth > 0
+		}
+
+		data.devices = append(data.devices, d)
+	}
+
+	if n := len(data.devices); n > 0 {
+		data.devices[n-1].leaf = data.devices[n-1].depth > 0
+	}
+
+	return data, nil
+}
+
+// GetControllersIDs - get pool names
+func (v ZFSVendor) GetControllersIDs() ([]string, error) {
+	pools, err := v.pools()
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, p := range pools {
+		data = append(data, p.name)
+	}
+
+	return data, nil
+}
+
+// GetLogicalDrivesIDs - get top level vdevs of pool 'controllerID'
+func (v ZFSVendor) GetLogicalDrivesIDs(controllerID string) ([]string, error) {
+	status, err := v.status(controllerID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, d := range status.vdevs() {
+		data = append(data, d.name)
+	}
+
+	return data, nil
+}
+
+// GetPhysicalDrivesIDs - get leaf devices of pool 'controllerID'
+func (v ZFSVendor) GetPhysicalDrivesIDs(controllerID string) ([]string, error) {
+	status, err := v.status(controllerID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := []string{}
+
+	for _, d := range status.leaves() {
+		data = append(data, d.name)
+	}
+
+	return data, nil
+}
+
+// GetControllerStatus - get pool status, capacity and scrub or resilver state
+func (v ZFSVendor) GetControllerStatus(controllerID string) (Controller, error) {
+	pools, err := v.pools()
+	if err != nil {
+		return Controller{}, err
+	}
+
+	for _, p := range pools {
+		if p.name != controllerID {
+			continue
+		}
+
+		status, err := v.status(p.name)
+		if err != nil {
+			return Controller{}, err
+		}
+
+		state := zfsPoolStates.Get(p.health)
+
+		// pool is usable, but some files are damaged
+		if len(status.errors) != 0 && status.errors != "No known data errors" {
+			state = state.Worse(StateWarning)
+		}
+
+		data := Controller{
+			Status:   p.health,
+			Health:   NewHealth(state),
+			Size:     p.size,
+			Capacity: p.capacity,
+			Scan:     status.scan,
+		}
+
+		if strings.Contains(status.scan, "in progress") {
+			data.Progress = status.progress
+		}
+
+		return data, nil
+	}
+
+	return Controller{}, NewUnknownDeviceError("pool '%s' not found", controllerID)
+}
+
+// GetLDStatus - get top level vdev status, 'deviceID' is vdev name ('raidz2-0') or disk of single disk vdev
+func (v ZFSVendor) GetLDStatus(controllerID string, deviceID string) (LogicalDrive, error) {
+	status, err := v.status(controllerID)
+	if err != nil {
+		return LogicalDrive{}, err
+	}
+
+	for _, d := range status.vdevs() {
+		if d.name != deviceID {
+			continue
+		}
+
+		// vdev name is type with index ('raidz2-0'), older releases print type alone
+		raidMode := "stripe"
+		if !d.leaf {
+			raidMode = d.name
+			if i := strings.LastIndex(d.name, "-"); i > 0 {
+				raidMode = d.name[:i]
+			}
+		}
+
+		if len(d.class) != 0 {
+			raidMode = d.class + " " + raidMode
+		}
+
+		var failed int
+		for _, c := range status.children(d.name) {
+			if zfsDeviceStates.Get(c.state) == StateCritical {
+				failed++
+			}
+		}
+
+		data := LogicalDrive{
+			Status:         d.state,
+			Health:         NewHealth(zfsDeviceStates.Get(d.state)),
+			RaidMode:       raidMode,
+			FailedDrives:   strconv.Itoa(failed),
+			ReadErrors:     d.read,
+			WriteErrors:    d.write,
+			ChecksumErrors: d.checksum,
+		}
+
+		return data, nil
+	}
+
+	return LogicalDrive{}, NewUnknownDeviceError("vdev '%s' not found in pool '%s'", deviceID, controllerID)
+}
+
+// GetPDStatus - get leaf device status, 'deviceID' is device name as in 'zpool status'
+func (v ZFSVendor) GetPDStatus(controllerID string, deviceID string) (PhysicalDrive, error) {
+	status, err := v.status(controllerID)
+	if err != nil {
+		return PhysicalDrive{}, err
+	}
+
+	for _, d := range status.leaves() {
+		if d.name != deviceID {
+			continue
+		}
+
+		// device being resilvered is reported online
+		s := d.state
+		if strings.Contains(d.note, "(resilvering)") {
+			s = "resilvering"
+		}
+
+		data := PhysicalDrive{
+			Status:         s,
+			Health:         NewHealth(zfsDeviceStates.Get(s)),
+			ReadErrors:     d.read,
+			WriteErrors:    d.write,
+			ChecksumErrors: d.checksum,
+		}
+
+		return data, nil
+	}
+
+	return PhysicalDrive{}, NewUnknownDeviceError("device '%s' not found in pool '%s'", deviceID, controllerID)
+}
+
+// SmartDevices - leaf device is disk or partition named by block device, /dev/disk/by-id link or path,
+// missing device is named by its guid and has no disk
+func (v ZFSVendor) SmartDevices(controllerID string, host ControllerHost) (map[string]SmartDevice, error) {
+	ids, err := v.GetPhysicalDrivesIDs(controllerID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]SmartDevice{}
+
+	for _, id := range ids {
+		if _, err := strconv.ParseUint(id, 10, 64); err == nil {
+			continue
+		}
+
+		data[id] = blockSmartDevice(id)
+	}
+
+	return data, nil
+}
+
+// NewZFSVendor - ZFS pools, 'execPath' is zpool binary
+func NewZFSVendor(execPath string, runner Runner) Vendor {
+	v := ZFSVendor{execPath: execPath, runner: runner}
+	return v
+}